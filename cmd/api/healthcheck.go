@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"expvar"
 	"net/http"
 )
 
@@ -19,3 +21,26 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// hiddenDebugVars are left out of the debug variables. The command line carries the secrets passed
+// as flags, such as the SMTP password.
+var hiddenDebugVars = map[string]bool{
+	"cmdline":  true,
+	"memstats": true,
+}
+
+func (app *application) debugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := envelope{}
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !hiddenDebugVars[kv.Key] {
+			vars[kv.Key] = json.RawMessage(kv.Value.String())
+		}
+	})
+
+	err := app.writeJSON(w, http.StatusOK, vars, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugVarsHandler(t *testing.T) {
+	expvar.NewInt("test_counter").Set(7)
+
+	var app application
+
+	rr := httptest.NewRecorder()
+	app.debugVarsHandler(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var vars map[string]json.RawMessage
+
+	err := json.Unmarshal(rr.Body.Bytes(), &vars)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(vars["test_counter"]) != "7" {
+		t.Errorf("test_counter = %s, want 7", vars["test_counter"])
+	}
+
+	for key := range hiddenDebugVars {
+		if _, ok := vars[key]; ok {
+			t.Errorf("%s is exposed", key)
+		}
+	}
+}
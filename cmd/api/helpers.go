@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/makarellav/cinego/internal/data"
 	"github.com/makarellav/cinego/internal/validator"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type envelope map[string]any
@@ -136,6 +139,68 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue *bool, v *validator.Validator) *bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+
+	if err != nil {
+		v.AddKey(key, "must be a boolean value")
+
+		return defaultValue
+	}
+
+	return &b
+}
+
+// readTime accepts either a full RFC 3339 timestamp or a plain date, which is read as midnight UTC.
+func (app *application) readTime(qs url.Values, key string, defaultValue *time.Time, v *validator.Validator) *time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+
+		if err == nil {
+			return &t
+		}
+	}
+
+	v.AddKey(key, "must be a date (2006-01-02) or an RFC 3339 timestamp")
+
+	return defaultValue
+}
+
+// parseDate parses a date in the 2006-01-02 format, adding a validation error for key if it isn't one.
+func (app *application) parseDate(v *validator.Validator, key string, value string) *time.Time {
+	t, err := time.Parse(time.DateOnly, value)
+
+	if err != nil {
+		v.AddKey(key, "must be a date (2006-01-02)")
+
+		return nil
+	}
+
+	return &t
+}
+
+func (app *application) clientIP(r *http.Request) (string, error) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid remote address %q: %w", r.RemoteAddr, err)
+	}
+
+	return ip, nil
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
@@ -151,3 +216,75 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// requestPermissions returns the permissions of the credentials used for the request. JWTs and API
+// keys carry their own permissions, otherwise they are looked up for the user.
+func (app *application) requestPermissions(r *http.Request) (data.Permissions, error) {
+	permissions, ok := app.contextGetPermissions(r)
+
+	if ok {
+		return permissions, nil
+	}
+
+	return app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+}
+
+// movieETag identifies the version of a movie. It is the strong validator clients send back in
+// If-Match to update or delete the movie they have seen.
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d"`, movie.Version)
+}
+
+// etagMatchesStrong reports whether etag is listed in an If-Match header. Weak validators never
+// match, as required for If-Match.
+func etagMatchesStrong(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || (!strings.HasPrefix(candidate, "W/") && candidate == etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// etagMatchesWeak reports whether etag is listed in an If-None-Match header, comparing weak and
+// strong validators by their value only.
+func etagMatchesWeak(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch sends an error response and returns false if the If-Match header of the request
+// doesn't match the current version of the movie.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, movie *data.Movie) bool {
+	ifMatch := r.Header.Get("If-Match")
+
+	if ifMatch == "" {
+		if app.config.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+
+			return false
+		}
+
+		return true
+	}
+
+	if !etagMatchesStrong(ifMatch, movieETag(movie)) {
+		app.preconditionFailedResponse(w, r)
+
+		return false
+	}
+
+	return true
+}
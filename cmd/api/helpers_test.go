@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/makarellav/cinego/internal/data"
+	"testing"
+)
+
+func TestETagMatchesStrong(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		want   bool
+	}{
+		{"exact", `"3"`, `"3"`, true},
+		{"different version", `"2"`, `"3"`, false},
+		{"unquoted", `3`, `"3"`, false},
+		{"wildcard", `*`, `"3"`, true},
+		{"in a list", `"1", "3"`, `"3"`, true},
+		{"list without it", `"1", "2"`, `"3"`, false},
+		{"weak candidate", `W/"3"`, `"3"`, false},
+		{"weak candidate in a list", `W/"3", "4"`, `"3"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatchesStrong(tt.header, tt.etag); got != tt.want {
+				t.Errorf("etagMatchesStrong(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestETagMatchesWeak(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		want   bool
+	}{
+		{"exact", `"3"`, `"3"`, true},
+		{"weak candidate", `W/"3"`, `"3"`, true},
+		{"different version", `"2"`, `"3"`, false},
+		{"wildcard", `*`, `"3"`, true},
+		{"in a list", `"1", W/"3"`, `"3"`, true},
+		{"list without it", `"1", W/"2"`, `"3"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatchesWeak(tt.header, tt.etag); got != tt.want {
+				t.Errorf("etagMatchesWeak(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMovieETagRoundTrip checks that the ETag sent with a movie is accepted back in both If-Match
+// and If-None-Match, and stops matching once the movie changes.
+func TestMovieETagRoundTrip(t *testing.T) {
+	movie := &data.Movie{ID: 1, Title: "Casablanca", Version: 3}
+	etag := movieETag(movie)
+
+	if !etagMatchesStrong(etag, movieETag(movie)) {
+		t.Errorf("If-Match %s doesn't match the movie it came from", etag)
+	}
+
+	if !etagMatchesWeak(etag, movieETag(movie)) {
+		t.Errorf("If-None-Match %s doesn't match the movie it came from", etag)
+	}
+
+	movie.Version++
+
+	if etagMatchesStrong(etag, movieETag(movie)) || etagMatchesWeak(etag, movieETag(movie)) {
+		t.Errorf("%s still matches after the movie changed", etag)
+	}
+}
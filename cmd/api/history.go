@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/validator"
+	"net/http"
+	"time"
+)
+
+func (app *application) listHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MovieID int
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.MovieID = app.readInt(qs, "movie_id", 0, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-watched_at")
+	input.Filters.SortSafeList = []string{"watched_at", "title", "rating", "-watched_at", "-title", "-rating"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	entries, metadata, err := app.models.History.GetAll(app.contextGetUser(r).ID, int64(input.MovieID), input.Filters)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK,
+		envelope{
+			"metadata": metadata,
+			"history":  entries,
+		}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createHistoryEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MovieID   int64  `json:"movie_id"`
+		WatchedAt string `json:"watched_at"`
+		Rating    *int16 `json:"rating"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	entry := data.HistoryEntry{
+		UserID:    app.contextGetUser(r).ID,
+		MovieID:   input.MovieID,
+		WatchedAt: time.Now().UTC().Truncate(24 * time.Hour),
+		Rating:    input.Rating,
+	}
+
+	v := validator.New()
+
+	if input.WatchedAt != "" {
+		if watchedAt := app.parseDate(v, "watched_at", input.WatchedAt); watchedAt != nil {
+			entry.WatchedAt = *watchedAt
+		}
+	}
+
+	if data.ValidateHistoryEntry(v, &entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	entry.Movie, err = app.models.Movies.Get(entry.MovieID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddKey("movie_id", "movie not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.models.History.Insert(&entry)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"history_entry": entry}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteHistoryEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+
+	if err != nil {
+		app.notFoundResponse(w, r)
+
+		return
+	}
+
+	err = app.models.History.Delete(id, app.contextGetUser(r).ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "history entry successfully deleted"}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
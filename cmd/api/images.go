@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/images"
+	"github.com/makarellav/cinego/internal/storage"
+	"github.com/makarellav/cinego/internal/validator"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// imageSizes are the widths of the thumbnails generated for each kind of image. The uploaded image
+// is kept as well, as the original size.
+var imageSizes = map[string]map[string]int{
+	data.ImagePoster:   {"small": 154, "medium": 342, "large": 780},
+	data.ImageBackdrop: {"small": 300, "medium": 780, "large": 1280},
+}
+
+// minImageSizes are the smallest accepted dimensions for each kind of image.
+var minImageSizes = map[string][2]int{
+	data.ImagePoster:   {200, 300},
+	data.ImageBackdrop: {640, 360},
+}
+
+const (
+	maxImageDimension = 10_000
+	maxImagePixels    = 25_000_000
+)
+
+func imageKey(prefix, size, contentType string) string {
+	if size == "original" {
+		return prefix + "/original" + images.Extension(contentType)
+	}
+
+	return prefix + "/" + size + ".jpg"
+}
+
+func (app *application) uploadMovieImageHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+
+		if err != nil {
+			app.notFoundResponse(w, r)
+
+			return
+		}
+
+		_, err = app.models.Movies.Get(id)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		body, err := app.readImage(w, r)
+
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+
+			return
+		}
+
+		v := validator.New()
+
+		contentType, cfg, err := images.Inspect(body)
+
+		if err != nil {
+			v.AddKey("image", "must be a JPEG or PNG image")
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+
+		minSize := minImageSizes[kind]
+
+		v.Check(cfg.Width >= minSize[0] && cfg.Height >= minSize[1], "image", fmt.Sprintf("must be at least %dx%d pixels", minSize[0], minSize[1]))
+		v.Check(cfg.Width <= maxImageDimension && cfg.Height <= maxImageDimension, "image", fmt.Sprintf("must not be larger than %dx%d pixels", maxImageDimension, maxImageDimension))
+		v.Check(cfg.Width*cfg.Height <= maxImagePixels, "image", "has too many pixels")
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+
+		img, err := images.Decode(body)
+
+		if err != nil {
+			v.AddKey("image", "could not be decoded")
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+
+		random := make([]byte, 8)
+
+		_, err = rand.Read(random)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		// every upload gets its own prefix, so that cached copies of a replaced image are never served
+		// for the new one
+		image := data.MovieImage{
+			MovieID:     id,
+			Kind:        kind,
+			KeyPrefix:   fmt.Sprintf("movies/%d/%s/%s", id, kind, hex.EncodeToString(random)),
+			ContentType: contentType,
+			Width:       cfg.Width,
+			Height:      cfg.Height,
+		}
+
+		type object struct {
+			data        []byte
+			contentType string
+		}
+
+		objects := map[string]object{
+			imageKey(image.KeyPrefix, "original", contentType): {body, contentType},
+		}
+
+		var sizes []string
+		var widths []int
+
+		for size, width := range imageSizes[kind] {
+			sizes = append(sizes, size)
+			widths = append(widths, width)
+		}
+
+		thumbnails, err := images.Thumbnails(img, widths)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		for i, size := range sizes {
+			objects[imageKey(image.KeyPrefix, size, contentType)] = object{thumbnails[i], "image/jpeg"}
+		}
+
+		for key, object := range objects {
+			err = app.storage.Put(r.Context(), key, object.data, object.contentType)
+
+			if err != nil {
+				app.deleteImageObjects(image.KeyPrefix, kind)
+				app.serverErrorResponse(w, r, err)
+
+				return
+			}
+		}
+
+		previous, err := app.models.Images.Replace(&image)
+
+		if err != nil {
+			app.deleteImageObjects(image.KeyPrefix, kind)
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		if previous != "" {
+			app.deleteImageObjects(previous, kind)
+		}
+
+		image.URLs = imageURLs(id, kind)
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"image": image}, nil)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+func (app *application) showMovieImageHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+
+		if err != nil {
+			app.notFoundResponse(w, r)
+
+			return
+		}
+
+		v := validator.New()
+
+		size := app.readString(r.URL.Query(), "size", "original")
+
+		if _, ok := imageSizes[kind][size]; !ok && size != "original" {
+			v.AddKey("size", "invalid size value")
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+
+		image, err := app.models.Images.Get(id, kind)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		key := imageKey(image.KeyPrefix, size, image.ContentType)
+		etag := fmt.Sprintf(`"%s"`, strings.ReplaceAll(key, "/", "-"))
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		object, contentType, err := app.storage.Get(r.Context(), key)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		defer object.Close()
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Last-Modified", image.UpdatedAt.UTC().Format(http.TimeFormat))
+
+		_, err = io.Copy(w, object)
+
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+func (app *application) deleteMovieImageHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+
+		if err != nil {
+			app.notFoundResponse(w, r)
+
+			return
+		}
+
+		image, err := app.models.Images.Delete(id, kind)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		app.deleteImageObjects(image.KeyPrefix, kind)
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"message": kind + " successfully deleted"}, nil)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// readImage reads an image from a multipart form field named image, or otherwise from the raw
+// request body.
+func (app *application) readImage(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.storage.maxUploadBytes)
+
+	var src io.Reader = r.Body
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "multipart/form-data" {
+		mr, err := r.MultipartReader()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			part, err := mr.NextPart()
+
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil, errors.New(`multipart body must contain an "image" field`)
+				}
+
+				return nil, err
+			}
+
+			if part.FormName() == "image" {
+				src = part
+
+				break
+			}
+		}
+	}
+
+	body, err := io.ReadAll(src)
+
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			return nil, fmt.Errorf("image must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return nil, err
+		}
+	}
+
+	if len(body) == 0 {
+		return nil, errors.New("image must not be empty")
+	}
+
+	return body, nil
+}
+
+// deleteImageObjects removes the objects of an image from storage in the background.
+func (app *application) deleteImageObjects(prefix, kind string) {
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// the original is stored with the extension of its content type, which isn't known here
+		keys := []string{prefix + "/original.jpg", prefix + "/original.png"}
+
+		for size := range imageSizes[kind] {
+			keys = append(keys, prefix+"/"+size+".jpg")
+		}
+
+		for _, key := range keys {
+			err := app.storage.Delete(ctx, key)
+
+			if err != nil {
+				app.logger.Error(err.Error(), "key", key)
+			}
+		}
+	})
+}
+
+func imageURLs(movieID int64, kind string) map[string]string {
+	urls := map[string]string{"original": fmt.Sprintf("/v1/movies/%d/%s", movieID, kind)}
+
+	for size := range imageSizes[kind] {
+		urls[size] = fmt.Sprintf("/v1/movies/%d/%s?size=%s", movieID, kind, size)
+	}
+
+	return urls
+}
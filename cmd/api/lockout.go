@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"github.com/makarellav/cinego/internal/data"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	// failureWindow is how long failed logins are remembered for a key after the most recent one
+	failureWindow = 24 * time.Hour
+	// loginAttemptsPurgeInterval is how often forgotten failed logins are removed from the database
+	loginAttemptsPurgeInterval = time.Hour
+)
+
+// checkLoginLockout writes an error response and returns false if logins from the client IP address
+// or for the email address are currently locked out.
+func (app *application) checkLoginLockout(w http.ResponseWriter, r *http.Request, email, ip string) bool {
+	now := time.Now()
+
+	attempt, err := app.models.Logins.Get(data.IPLoginKey(ip))
+
+	switch {
+	case err == nil && attempt.IsLocked(now):
+		app.tooManyLoginAttemptsResponse(w, r, attempt.LockedUntil.Sub(now))
+
+		return false
+	case err != nil && !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+
+		return false
+	}
+
+	attempt, err = app.models.Logins.Get(data.EmailLoginKey(email))
+
+	switch {
+	case err == nil && attempt.IsLocked(now):
+		app.accountTemporarilyLockedResponse(w, r, attempt.LockedUntil.Sub(now))
+
+		return false
+	case err != nil && !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+
+		return false
+	}
+
+	return true
+}
+
+// recordFailedLogin counts a failed login for both the email address and the client IP address, and
+// locks them out once they reach their limit. The owner of the account, if there is one, is told
+// about it when the account gets locked.
+func (app *application) recordFailedLogin(user *data.User, email, ip string) error {
+	attempt, err := app.models.Logins.RecordFailure(data.IPLoginKey(ip), failureWindow)
+
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures >= app.config.login.maxIPFailures {
+		err = app.models.Logins.Lock(attempt.Key, time.Now().Add(app.lockoutDuration(attempt.Failures-app.config.login.maxIPFailures)))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	attempt, err = app.models.Logins.RecordFailure(data.EmailLoginKey(email), failureWindow)
+
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures < app.config.login.maxFailures {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(app.lockoutDuration(attempt.Failures - app.config.login.maxFailures))
+
+	err = app.models.Logins.Lock(attempt.Key, lockedUntil)
+
+	if err != nil {
+		return err
+	}
+
+	if user != nil && app.config.login.notify && attempt.Failures == app.config.login.maxFailures {
+		app.background(func() {
+			emailData := map[string]any{
+				"failures":    attempt.Failures,
+				"ip":          ip,
+				"lockedUntil": lockedUntil.UTC().Format(time.RFC1123),
+			}
+
+			err := app.mailer.Send(user.Email, "login_suspicious.gohtml", emailData)
+
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	return nil
+}
+
+// resetFailedLogins forgets the failed logins for the email address after a successful login. The
+// count for the IP address is left alone, otherwise logging in to one account in between guesses
+// would be enough to get around it.
+func (app *application) resetFailedLogins(email string) error {
+	return app.models.Logins.Reset(data.EmailLoginKey(email))
+}
+
+// lockoutDuration doubles the lockout for every failure past the limit, up to the configured maximum.
+func (app *application) lockoutDuration(extraFailures int) time.Duration {
+	d := float64(app.config.login.lockout) * math.Pow(2, float64(extraFailures))
+
+	if d > float64(app.config.login.maxLockout) {
+		return app.config.login.maxLockout
+	}
+
+	return time.Duration(d)
+}
+
+// purgeLoginAttempts removes the failed logins that are no longer remembered, once right away and
+// then on every interval until done is closed.
+func (app *application) purgeLoginAttempts(done <-chan struct{}) {
+	app.background(func() {
+		ticker := time.NewTicker(loginAttemptsPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			count, err := app.models.Logins.DeleteExpired(failureWindow)
+
+			if err != nil {
+				app.logger.Error(err.Error())
+			} else if count > 0 {
+				app.logger.Info("purged login attempts", "count", count)
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	var app application
+	app.config.login.lockout = time.Minute
+	app.config.login.maxLockout = time.Hour
+
+	tests := []struct {
+		extraFailures int
+		want          time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{5, 32 * time.Minute},
+		{6, time.Hour},
+		{7, time.Hour},
+		{1000, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := app.lockoutDuration(tt.extraFailures); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.extraFailures, got, tt.want)
+		}
+	}
+}
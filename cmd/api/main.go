@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/jwt"
 	"github.com/makarellav/cinego/internal/mailer"
+	"github.com/makarellav/cinego/internal/oidc"
+	"github.com/makarellav/cinego/internal/storage"
 	"log/slog"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -26,7 +33,8 @@ type config struct {
 		maxOpenConns int
 		maxIdleTime  time.Duration
 	}
-	limiter struct {
+	permissionsCacheTTL time.Duration
+	limiter             struct {
 		rps     float64
 		burst   int
 		enabled bool
@@ -41,14 +49,67 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	requireIfMatch bool
+	trash          struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	storage struct {
+		backend        string
+		dir            string
+		maxUploadBytes int64
+		s3             storage.S3Config
+	}
+	oidc struct {
+		name         string
+		issuer       string
+		clientID     string
+		clientSecret string
+		redirectURL  string
+	}
+	password struct {
+		minLength      int
+		minCharClasses int
+		minEntropy     float64
+		breachedDir    string
+		hash           struct {
+			algorithm         string
+			bcryptCost        int
+			argon2Memory      uint
+			argon2Iterations  uint
+			argon2Parallelism uint
+		}
+	}
+	login struct {
+		maxFailures   int
+		maxIPFailures int
+		lockout       time.Duration
+		maxLockout    time.Duration
+		notify        bool
+	}
+	auth struct {
+		mode       string
+		refreshTTL time.Duration
+		jwt        struct {
+			algorithm  string
+			keys       string
+			signingKey string
+			issuer     string
+			ttl        time.Duration
+		}
+	}
 }
 
 type application struct {
-	config config
-	logger *slog.Logger
-	models *data.Models
-	mailer *mailer.Mailer
-	wg     sync.WaitGroup
+	config         config
+	logger         *slog.Logger
+	models         *data.Models
+	mailer         *mailer.Mailer
+	jwt            *jwt.KeySet
+	oidc           map[string]*oidc.Provider
+	passwordPolicy *data.PasswordPolicy
+	storage        storage.Storage
+	wg             sync.WaitGroup
 }
 
 func main() {
@@ -70,6 +131,8 @@ func main() {
 	flag.IntVar(&cfg.db.maxOpenConns, "db_max_open_conns", 25, "PostrgreSQL max open connections")
 	flag.DurationVar(&cfg.db.maxIdleTime, "db_max_idle_time", 15*time.Minute, "PostgreSQL max connection idle time")
 
+	flag.DurationVar(&cfg.permissionsCacheTTL, "permissions_cache_ttl", time.Minute, "How long user permissions are cached in memory (0 disables the cache)")
+
 	flag.Float64Var(&cfg.limiter.rps, "limiter_rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter_burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter_enabled", true, "Enable rate limiter")
@@ -93,8 +156,85 @@ func main() {
 		return nil
 	})
 
+	flag.BoolVar(&cfg.requireIfMatch, "require_if_match", false, "Reject movie updates and deletes without an If-Match header")
+
+	flag.DurationVar(&cfg.trash.retention, "trash_retention", 30*24*time.Hour, "How long deleted movies are kept in the trash before they are purged")
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash_purge_interval", time.Hour, "How often the trash is purged")
+
+	flag.StringVar(&cfg.storage.backend, "storage", "local", "Image storage backend (local|s3)")
+	flag.StringVar(&cfg.storage.dir, "storage_dir", "./uploads", "Directory for the local image storage")
+	flag.Int64Var(&cfg.storage.maxUploadBytes, "storage_max_upload_bytes", 10<<20, "Maximum size of an uploaded image in bytes")
+	flag.StringVar(&cfg.storage.s3.Endpoint, "s3_endpoint", os.Getenv("S3_ENDPOINT"), "S3 endpoint URL")
+	flag.StringVar(&cfg.storage.s3.Region, "s3_region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.storage.s3.Bucket, "s3_bucket", os.Getenv("S3_BUCKET"), "S3 bucket")
+	flag.StringVar(&cfg.storage.s3.AccessKey, "s3_access_key", os.Getenv("S3_ACCESS_KEY"), "S3 access key")
+	flag.StringVar(&cfg.storage.s3.SecretKey, "s3_secret_key", os.Getenv("S3_SECRET_KEY"), "S3 secret key")
+	flag.BoolVar(&cfg.storage.s3.PathStyle, "s3_path_style", true, "Address the S3 bucket in the URL path instead of the host name")
+
+	flag.StringVar(&cfg.oidc.name, "oidc_name", "corporate", "OpenID Connect provider name, used in the login URLs")
+	flag.StringVar(&cfg.oidc.issuer, "oidc_issuer", os.Getenv("OIDC_ISSUER"), "OpenID Connect issuer URL (leave empty to disable)")
+	flag.StringVar(&cfg.oidc.clientID, "oidc_client_id", os.Getenv("OIDC_CLIENT_ID"), "OpenID Connect client ID")
+	flag.StringVar(&cfg.oidc.clientSecret, "oidc_client_secret", os.Getenv("OIDC_CLIENT_SECRET"), "OpenID Connect client secret")
+	flag.StringVar(&cfg.oidc.redirectURL, "oidc_redirect_url", os.Getenv("OIDC_REDIRECT_URL"), "OpenID Connect redirect URL, pointing at /v1/oauth/{provider}/callback")
+
+	flag.IntVar(&cfg.password.minLength, "password_min_length", 8, "Minimum password length")
+	flag.IntVar(&cfg.password.minCharClasses, "password_min_char_classes", 2, "Minimum number of character classes (lowercase, uppercase, digits, symbols) in a password")
+	flag.Float64Var(&cfg.password.minEntropy, "password_min_entropy", 0, "Minimum estimated password strength in bits (0 disables the check)")
+	flag.StringVar(&cfg.password.breachedDir, "password_breached_dir", os.Getenv("PASSWORD_BREACHED_DIR"), "Directory of breached password hash range files (leave empty to disable)")
+	defaultHashing := data.DefaultHashParams()
+
+	flag.StringVar(&cfg.password.hash.algorithm, "password_hash", data.HashBcrypt, "Password hashing algorithm (bcrypt|argon2id)")
+	flag.IntVar(&cfg.password.hash.bcryptCost, "password_bcrypt_cost", defaultHashing.BcryptCost, "bcrypt cost")
+	flag.UintVar(&cfg.password.hash.argon2Memory, "password_argon2_memory", uint(defaultHashing.Argon2Memory), "argon2id memory in KiB")
+	flag.UintVar(&cfg.password.hash.argon2Iterations, "password_argon2_iterations", uint(defaultHashing.Argon2Iterations), "argon2id iterations")
+	flag.UintVar(&cfg.password.hash.argon2Parallelism, "password_argon2_parallelism", uint(defaultHashing.Argon2Parallelism), "argon2id parallelism")
+
+	flag.IntVar(&cfg.login.maxFailures, "login_max_failures", 5, "Failed logins for an account before it is temporarily locked")
+	flag.IntVar(&cfg.login.maxIPFailures, "login_max_ip_failures", 20, "Failed logins from an IP address before it is temporarily locked")
+	flag.DurationVar(&cfg.login.lockout, "login_lockout", time.Minute, "Initial lockout after too many failed logins, doubled on every further failure")
+	flag.DurationVar(&cfg.login.maxLockout, "login_max_lockout", time.Hour, "Maximum lockout after too many failed logins")
+	flag.BoolVar(&cfg.login.notify, "login_notify", true, "Email account owners when their account gets locked")
+
+	flag.StringVar(&cfg.auth.mode, "auth_mode", "token", "Authentication token mode (token|jwt)")
+	flag.DurationVar(&cfg.auth.refreshTTL, "auth_refresh_ttl", 30*24*time.Hour, "Refresh token lifetime")
+	flag.StringVar(&cfg.auth.jwt.algorithm, "jwt_algorithm", jwt.AlgHS256, "JWT signing algorithm (HS256|EdDSA)")
+	flag.StringVar(&cfg.auth.jwt.keys, "jwt_keys", os.Getenv("JWT_KEYS"), "JWT keys (space separated kid:base64 pairs)")
+	flag.StringVar(&cfg.auth.jwt.signingKey, "jwt_signing_kid", os.Getenv("JWT_SIGNING_KID"), "ID of the JWT key used to sign new tokens")
+	flag.StringVar(&cfg.auth.jwt.issuer, "jwt_issuer", "cinego", "JWT issuer")
+	flag.DurationVar(&cfg.auth.jwt.ttl, "jwt_ttl", 15*time.Minute, "JWT lifetime")
+
 	flag.Parse()
 
+	if cfg.auth.mode != "token" && cfg.auth.mode != "jwt" {
+		logger.Error(fmt.Sprintf("invalid auth mode %q", cfg.auth.mode))
+		os.Exit(1)
+	}
+
+	hashing, err := hashParams(cfg)
+
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	var keys *jwt.KeySet
+
+	if cfg.auth.mode == "jwt" {
+		keys, err = loadJWTKeys(cfg)
+
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	store, err := openStorage(cfg)
+
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	db, err := openDB(cfg)
 	defer db.Close()
 
@@ -105,11 +245,38 @@ func main() {
 
 	logger.Info("database connection pool established")
 
+	providers := make(map[string]*oidc.Provider)
+
+	if cfg.oidc.issuer != "" {
+		providers[cfg.oidc.name] = oidc.New(oidc.Config{
+			Name:         cfg.oidc.name,
+			Issuer:       cfg.oidc.issuer,
+			ClientID:     cfg.oidc.clientID,
+			ClientSecret: cfg.oidc.clientSecret,
+			RedirectURL:  cfg.oidc.redirectURL,
+		})
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		models:  data.NewModels(db, cfg.permissionsCacheTTL, hashing),
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		jwt:     keys,
+		oidc:    providers,
+		storage: store,
+		passwordPolicy: &data.PasswordPolicy{
+			MinLength:      cfg.password.minLength,
+			MinCharClasses: cfg.password.minCharClasses,
+			MinEntropy:     cfg.password.minEntropy,
+			BreachedDir:    cfg.password.breachedDir,
+		},
+	}
+
+	if app.models.Permissions.Cache != nil {
+		expvar.Publish("permissions_cache", expvar.Func(func() any {
+			return app.models.Permissions.Cache.Stats()
+		}))
 	}
 
 	err = app.serve()
@@ -120,6 +287,40 @@ func main() {
 	}
 }
 
+// hashParams returns the password hashing parameters from the configuration, checking that they fit
+// before they are narrowed down to the types argon2 takes.
+func hashParams(cfg config) (data.HashParams, error) {
+	switch {
+	case cfg.password.hash.argon2Memory > math.MaxUint32:
+		return data.HashParams{}, fmt.Errorf("argon2id memory must be at most %d KiB", uint32(math.MaxUint32))
+	case cfg.password.hash.argon2Iterations > math.MaxUint32:
+		return data.HashParams{}, fmt.Errorf("argon2id iterations must be at most %d", uint32(math.MaxUint32))
+	case cfg.password.hash.argon2Parallelism > math.MaxUint8:
+		return data.HashParams{}, fmt.Errorf("argon2id parallelism must be at most %d", math.MaxUint8)
+	}
+
+	params := data.HashParams{
+		Algorithm:         cfg.password.hash.algorithm,
+		BcryptCost:        cfg.password.hash.bcryptCost,
+		Argon2Memory:      uint32(cfg.password.hash.argon2Memory),
+		Argon2Iterations:  uint32(cfg.password.hash.argon2Iterations),
+		Argon2Parallelism: uint8(cfg.password.hash.argon2Parallelism),
+	}
+
+	return params, params.Validate()
+}
+
+func openStorage(cfg config) (storage.Storage, error) {
+	switch cfg.storage.backend {
+	case "local":
+		return storage.NewLocal(cfg.storage.dir)
+	case "s3":
+		return storage.NewS3(cfg.storage.s3)
+	default:
+		return nil, fmt.Errorf("invalid storage backend %q", cfg.storage.backend)
+	}
+}
+
 func openDB(cfg config) (*pgxpool.Pool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -140,3 +341,35 @@ func openDB(cfg config) (*pgxpool.Pool, error) {
 
 	return pool, nil
 }
+
+func loadJWTKeys(cfg config) (*jwt.KeySet, error) {
+	var keys []*jwt.Key
+
+	for _, pair := range strings.Fields(cfg.auth.jwt.keys) {
+		id, encoded, ok := strings.Cut(pair, ":")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid jwt key %q, expected kid:base64", pair)
+		}
+
+		material, err := base64.StdEncoding.DecodeString(encoded)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt key %q: %w", id, err)
+		}
+
+		key, err := jwt.NewKey(id, cfg.auth.jwt.algorithm, material)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwt auth mode requires at least one key")
+	}
+
+	return jwt.NewKeySet(cfg.auth.jwt.issuer, cfg.auth.jwt.signingKey, keys...)
+}
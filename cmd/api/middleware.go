@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/jwt"
 	"github.com/makarellav/cinego/internal/validator"
 	"golang.org/x/time/rate"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -85,15 +87,35 @@ func (app *application) rateLimiter(next http.Handler) http.Handler {
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authentication")
+		w.Header().Add("Vary", "X-API-Key")
 
+		apiKey := r.Header.Get("X-API-Key")
 		authorizationHeader := r.Header.Get("Authorization")
 
-		if authorizationHeader == "" {
+		if apiKey == "" && authorizationHeader == "" {
 			r = app.contextSetUser(r, data.AnonymousUser)
 			next.ServeHTTP(w, r)
 			return
 		}
 
+		if apiKey == "" {
+			scheme, credentials, ok := strings.Cut(authorizationHeader, " ")
+
+			if ok && scheme == "ApiKey" {
+				apiKey = credentials
+			}
+		}
+
+		if apiKey != "" {
+			r, ok := app.authenticateAPIKey(w, r, apiKey)
+
+			if ok {
+				next.ServeHTTP(w, r)
+			}
+
+			return
+		}
+
 		headerParts := strings.Split(authorizationHeader, " ")
 
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
@@ -104,6 +126,38 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		token := headerParts[1]
 
+		if app.jwt != nil && jwt.IsJWT(token) {
+			claims, err := app.jwt.Verify(token)
+
+			if err != nil {
+				app.invalidAuthenticationTokenResponse(w, r)
+
+				return
+			}
+
+			userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+
+			if err != nil {
+				app.invalidAuthenticationTokenResponse(w, r)
+
+				return
+			}
+
+			// locking or deleting the account revokes its refresh tokens, so a stateless token stops
+			// working once it expires
+			user := &data.User{
+				ID:        userID,
+				Activated: claims.Activated,
+			}
+
+			r = app.contextSetUser(r, user)
+			r = app.contextSetToken(r, token)
+			r = app.contextSetPermissions(r, claims.Permissions)
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		v := validator.New()
 
 		if data.ValidateToken(v, token); !v.Valid() {
@@ -125,12 +179,102 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.Locked {
+			app.lockedAccountResponse(w, r)
+
+			return
+		}
+
+		err = app.models.Tokens.Touch(data.ScopeAuthentication, token)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, token)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticateAPIKey looks up the user an API key belongs to. The request is limited to the
+// permissions of the key, and only those the user still has. If the key is not valid an error
+// response is sent and false is returned.
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, plaintextKey string) (*http.Request, bool) {
+	v := validator.New()
+
+	if data.ValidateAPIKeyPlaintext(v, plaintextKey); !v.Valid() {
+		app.invalidAPIKeyResponse(w, r)
+
+		return nil, false
+	}
+
+	key, err := app.models.APIKeys.GetByKey(plaintextKey)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAPIKeyResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	user, err := app.models.Users.Get(key.UserID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAPIKeyResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+
+		return nil, false
+	}
+
+	userPermissions, err := app.models.Permissions.GetAllForUser(user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return nil, false
+	}
+
+	permissions := data.Permissions{}
+
+	for _, code := range key.Permissions {
+		if userPermissions.Include(code) {
+			permissions = append(permissions, code)
+		}
+	}
+
+	err = app.models.APIKeys.Touch(key.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return nil, false
+	}
+
+	r = app.contextSetUser(r, user)
+	r = app.contextSetPermissions(r, permissions)
+	r = app.contextSetAPIKey(r, key)
+
+	return r, true
+}
+
 func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
@@ -161,14 +305,26 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthenticatedUser(fn)
 }
 
+// rejectAPIKey keeps API keys away from the account itself, its sessions and its keys, whatever
+// permissions they were given. Those are only managed with the user's own credentials.
+func (app *application) rejectAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := app.contextGetAPIKey(r); ok {
+			app.apiKeyNotAllowedResponse(w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		user := app.contextGetUser(r)
-
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		permissions, err := app.requestPermissions(r)
 
 		if err != nil {
-			app.notPermittedResponse(w, r)
+			app.serverErrorResponse(w, r, err)
 
 			return
 		}
@@ -195,10 +351,11 @@ func (app *application) enableCors(next http.Handler) http.Handler {
 			for i := range app.config.cors.trustedOrigins {
 				if app.config.cors.trustedOrigins[i] == origin {
 					w.Header().Add("Access-Control-Allow-Origin", origin)
+					w.Header().Add("Access-Control-Expose-Headers", "ETag")
 
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Allow-Methods") != "" {
 						w.Header().Add("Access-Control-Allow-Methods", "OPTIONS, PUT, POST, PATCH")
-						w.Header().Add("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Add("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key, If-Match, If-None-Match")
 
 						w.WriteHeader(http.StatusOK)
 						return
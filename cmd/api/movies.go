@@ -6,14 +6,23 @@ import (
 	"github.com/makarellav/cinego/internal/data"
 	"github.com/makarellav/cinego/internal/validator"
 	"net/http"
+	"slices"
 )
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title               string            `json:"title"`
+		OriginalTitle       string            `json:"original_title"`
+		Synopsis            string            `json:"synopsis"`
+		Year                int32             `json:"year"`
+		ReleaseDate         string            `json:"release_date"`
+		Runtime             data.Runtime      `json:"runtime"`
+		Genres              []string          `json:"genres"`
+		SpokenLanguages     []string          `json:"spoken_languages"`
+		ProductionCountries []string          `json:"production_countries"`
+		Certifications      map[string]string `json:"certifications"`
+		IMDbID              *string           `json:"imdb_id"`
+		TMDBID              *int64            `json:"tmdb_id"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -25,28 +34,50 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	movie := data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:               input.Title,
+		OriginalTitle:       input.OriginalTitle,
+		Synopsis:            input.Synopsis,
+		Year:                input.Year,
+		Runtime:             input.Runtime,
+		Genres:              data.NormalizeGenres(input.Genres),
+		SpokenLanguages:     input.SpokenLanguages,
+		ProductionCountries: input.ProductionCountries,
+		Certifications:      input.Certifications,
+		IMDbID:              input.IMDbID,
+		TMDBID:              input.TMDBID,
+	}
+
+	genres, err := app.models.Genres.GetAllNames()
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, &movie); !v.Valid() {
+	if input.ReleaseDate != "" {
+		movie.ReleaseDate = app.parseDate(v, "release_date", input.ReleaseDate)
+	}
+
+	if data.ValidateMovie(v, &movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 
 		return
 	}
 
-	err = app.models.Movies.Insert(&movie)
+	err = app.models.Movies.Insert(&movie, app.contextGetUser(r).ID)
 
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.movieWriteErrorResponse(w, r, v, err)
+
+		return
 	}
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	headers.Set("ETag", movieETag(&movie))
 
 	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
 
@@ -77,7 +108,43 @@ func (app *application) getMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	v := validator.New()
+
+	include := app.readCSV(r.URL.Query(), "include", []string{})
+
+	for _, value := range include {
+		v.Check(validator.PermittedValue(value, "credits"), "include", "invalid include value")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	etag := movieETag(movie)
+
+	w.Header().Set("ETag", etag)
+
+	if etagMatchesWeak(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	env := envelope{"movie": movie}
+
+	if slices.Contains(include, "credits") {
+		env["credits"], err = app.models.Credits.GetAllForMovie(movie.ID)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -106,11 +173,25 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !app.checkIfMatch(w, r, movie) {
+		return
+	}
+
+	before := *movie
+
 	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+		Title               *string           `json:"title"`
+		OriginalTitle       *string           `json:"original_title"`
+		Synopsis            *string           `json:"synopsis"`
+		Year                *int32            `json:"year"`
+		ReleaseDate         *string           `json:"release_date"`
+		Runtime             *data.Runtime     `json:"runtime"`
+		Genres              []string          `json:"genres"`
+		SpokenLanguages     []string          `json:"spoken_languages"`
+		ProductionCountries []string          `json:"production_countries"`
+		Certifications      map[string]string `json:"certifications"`
+		IMDbID              *string           `json:"imdb_id"`
+		TMDBID              *int64            `json:"tmdb_id"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -134,18 +215,72 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if input.Genres != nil {
-		movie.Genres = input.Genres
+		movie.Genres = data.NormalizeGenres(input.Genres)
+	}
+
+	if input.OriginalTitle != nil {
+		movie.OriginalTitle = *input.OriginalTitle
+	}
+
+	if input.Synopsis != nil {
+		movie.Synopsis = *input.Synopsis
+	}
+
+	if input.SpokenLanguages != nil {
+		movie.SpokenLanguages = input.SpokenLanguages
+	}
+
+	if input.ProductionCountries != nil {
+		movie.ProductionCountries = input.ProductionCountries
+	}
+
+	if input.Certifications != nil {
+		movie.Certifications = input.Certifications
+	}
+
+	// empty external IDs clear them
+	if input.IMDbID != nil {
+		movie.IMDbID = input.IMDbID
+
+		if *input.IMDbID == "" {
+			movie.IMDbID = nil
+		}
+	}
+
+	if input.TMDBID != nil {
+		movie.TMDBID = input.TMDBID
+
+		if *input.TMDBID == 0 {
+			movie.TMDBID = nil
+		}
+	}
+
+	genres, err := app.models.Genres.GetAllNames()
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	// an empty release date clears it
+	if input.ReleaseDate != nil {
+		movie.ReleaseDate = nil
+
+		if *input.ReleaseDate != "" {
+			movie.ReleaseDate = app.parseDate(v, "release_date", *input.ReleaseDate)
+		}
+	}
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(&before, movie, app.contextGetUser(r).ID)
 
 	if err != nil {
 		switch {
@@ -154,13 +289,16 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 
 			return
 		default:
-			app.serverErrorResponse(w, r, err)
+			app.movieWriteErrorResponse(w, r, v, err)
 
 			return
 		}
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -178,7 +316,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	movie, err := app.models.Movies.Get(id)
 
 	if err != nil {
 		switch {
@@ -191,7 +329,24 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	if !app.checkIfMatch(w, r, movie) {
+		return
+	}
+
+	err = app.models.Movies.Delete(movie, app.contextGetUser(r).ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully moved to the trash"}, nil)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -200,8 +355,10 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		Title    string
+		Genres   []string
+		Director string
+		Actor    string
 		data.Filters
 	}
 
@@ -209,11 +366,13 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	qs := r.URL.Query()
 
 	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.Genres = data.NormalizeGenres(app.readCSV(qs, "genres", []string{}))
+	input.Director = app.readString(qs, "director", "")
+	input.Actor = app.readString(qs, "actor", "")
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "rating", "-id", "-title", "-year", "-runtime", "-rating"}
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
@@ -221,7 +380,7 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Director, input.Actor, input.Filters)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -239,3 +398,55 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) lookupMovieHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	imdbID := app.readString(qs, "imdb", "")
+	tmdbID := app.readInt(qs, "tmdb", 0, v)
+
+	v.Check(imdbID != "" || tmdbID != 0, "imdb", "an imdb or tmdb id must be provided")
+	v.Check(imdbID == "" || tmdbID == 0, "imdb", "must not be provided together with tmdb")
+	v.Check(imdbID == "" || validator.Mathces(imdbID, data.IMDbIDRX), "imdb", "must be an IMDb title ID (tt0000000)")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	movie, err := app.models.Movies.GetByExternalID(imdbID, int64(tmdbID))
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// movieWriteErrorResponse reports the external IDs that are already used by another movie as
+// validation errors.
+func (app *application) movieWriteErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, data.ErrDuplicateIMDbID):
+		v.AddKey("imdb_id", "a movie with this imdb id already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrDuplicateTMDBID):
+		v.AddKey("tmdb_id", "a movie with this tmdb id already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
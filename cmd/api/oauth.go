@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/oidc"
+	"net/http"
+	"time"
+)
+
+func (app *application) startOAuthHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidc[chi.URLParam(r, "provider")]
+
+	if !ok {
+		app.notFoundResponse(w, r)
+
+		return
+	}
+
+	state := data.OAuthState{
+		Provider: provider.Name,
+		Expiry:   time.Now().Add(10 * time.Minute),
+	}
+
+	var err error
+
+	for _, value := range []*string{&state.Plaintext, &state.Verifier, &state.Nonce} {
+		*value, err = oidc.GenerateVerifier()
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state.Plaintext, state.Nonce, state.Verifier)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.models.OAuthStates.Insert(&state)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	// browsers follow the redirect straight to the provider, API clients can read the URL from the body
+	headers := make(http.Header)
+	headers.Set("Location", authURL)
+
+	err = app.writeJSON(w, http.StatusFound, envelope{"authorization_url": authURL}, headers)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidc[chi.URLParam(r, "provider")]
+
+	if !ok {
+		app.notFoundResponse(w, r)
+
+		return
+	}
+
+	qs := r.URL.Query()
+
+	if providerError := qs.Get("error"); providerError != "" {
+		app.badRequestResponse(w, r, fmt.Errorf("identity provider returned an error: %s", providerError))
+
+		return
+	}
+
+	code := app.readString(qs, "code", "")
+	plaintextState := app.readString(qs, "state", "")
+
+	if code == "" || plaintextState == "" {
+		app.badRequestResponse(w, r, errors.New("code and state must be provided"))
+
+		return
+	}
+
+	state, err := app.models.OAuthStates.Consume(provider.Name, plaintextState)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.badRequestResponse(w, r, errors.New("invalid or expired state"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(r.Context(), code, state.Verifier)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchangeFailed):
+			app.logger.Warn(err.Error(), "provider", provider.Name)
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	claims, err := provider.Verify(r.Context(), rawIDToken, state.Nonce)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrInvalidIDToken):
+			app.logger.Warn(err.Error(), "provider", provider.Name)
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if claims.Email == "" {
+		app.errorResponse(w, r, http.StatusForbidden, "the identity provider did not supply an email address")
+
+		return
+	}
+
+	user, err := app.userForIdentity(provider.Name, claims)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnverifiedIdentityEmail):
+			app.errorResponse(w, r, http.StatusConflict, "an account with this email address already exists, but the identity provider did not verify that the address is yours")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+var errUnverifiedIdentityEmail = errors.New("identity email address is not verified")
+
+// userForIdentity returns the user linked to the identity. An identity seen for the first time is
+// linked to the user with the same email address if the provider verified it, and a new user is
+// created if there is none.
+func (app *application) userForIdentity(provider string, claims *oidc.Claims) (*data.User, error) {
+	user, err := app.models.Identities.GetUser(provider, claims.Subject)
+
+	if err == nil {
+		return user, nil
+	}
+
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user, err = app.models.Users.GetByEmail(claims.Email)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		user, err = app.createIdentityUser(claims)
+
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !claims.HasVerifiedEmail():
+		// anyone could claim the address at a provider that doesn't check it
+		return nil, errUnverifiedIdentityEmail
+	}
+
+	err = app.models.Identities.Link(provider, claims.Subject, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (app *application) createIdentityUser(claims *oidc.Claims) (*data.User, error) {
+	name := claims.Name
+
+	if name == "" {
+		name = claims.Email
+	}
+
+	user := data.User{
+		Name:      name,
+		Email:     claims.Email,
+		Activated: claims.HasVerifiedEmail(),
+	}
+
+	// the user signs in through the provider, so the local password is random and never handed out
+	randomBytes := make([]byte, 48)
+
+	_, err := rand.Read(randomBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = user.Password.Set(base64.RawURLEncoding.EncodeToString(randomBytes), app.models.Users.Hashing)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Users.Insert(&user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Permissions.AddForUser(user.ID, "movies:read", "reviews:write")
+
+	if err != nil {
+		return nil, err
+	}
+
+	// an unverified address has to be confirmed the same way as when registering
+	if !user.Activated {
+		token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeActivation)
+
+		if err != nil {
+			return nil, err
+		}
+
+		app.background(func() {
+			emailData := map[string]any{
+				"userID":          user.ID,
+				"activationToken": token.Plaintext,
+			}
+
+			err := app.mailer.Send(user.Email, "user_welcome.gohtml", emailData)
+
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	return &user, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/go-chi/chi/v5"
+	"github.com/makarellav/cinego/internal/data"
 	"net/http"
 )
 
@@ -20,19 +21,88 @@ func (app *application) routes() http.Handler {
 	r.NotFound(app.notFoundResponse)
 	r.MethodNotAllowed(app.methodNotAllowedResponse)
 
+	r.Get("/debug/vars", app.requirePermission("users:admin", app.debugVarsHandler))
+
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/healthcheck", app.healthcheckHandler)
 
 		r.Get("/movies", app.requirePermission("movies:read", app.listMoviesHandler))
 		r.Post("/movies", app.requirePermission("movies:write", app.createMovieHandler))
+		r.Get("/movies/trash", app.requirePermission("movies:write", app.listDeletedMoviesHandler))
+		r.Post("/movies/{id}/restore", app.requirePermission("movies:write", app.restoreMovieHandler))
+		r.Get("/movies/lookup", app.requirePermission("movies:read", app.lookupMovieHandler))
 		r.Get("/movies/{id}", app.requirePermission("movies:read", app.getMovieHandler))
 		r.Patch("/movies/{id}", app.requirePermission("movies:write", app.updateMovieHandler))
 		r.Delete("/movies/{id}", app.requirePermission("movies:write", app.deleteMovieHandler))
+		r.Get("/movies/{id}/poster", app.requirePermission("movies:read", app.showMovieImageHandler(data.ImagePoster)))
+		r.Put("/movies/{id}/poster", app.requirePermission("movies:write", app.uploadMovieImageHandler(data.ImagePoster)))
+		r.Delete("/movies/{id}/poster", app.requirePermission("movies:write", app.deleteMovieImageHandler(data.ImagePoster)))
+		r.Get("/movies/{id}/backdrop", app.requirePermission("movies:read", app.showMovieImageHandler(data.ImageBackdrop)))
+		r.Put("/movies/{id}/backdrop", app.requirePermission("movies:write", app.uploadMovieImageHandler(data.ImageBackdrop)))
+		r.Delete("/movies/{id}/backdrop", app.requirePermission("movies:write", app.deleteMovieImageHandler(data.ImageBackdrop)))
+		r.Get("/movies/{id}/revisions", app.requirePermission("movies:write", app.listMovieRevisionsHandler))
+		r.Post("/movies/{id}/revisions/{rev}/revert", app.requirePermission("movies:write", app.revertMovieHandler))
+		r.Get("/movies/{id}/reviews", app.requirePermission("movies:read", app.listMovieReviewsHandler))
+		r.Post("/movies/{id}/reviews", app.requirePermission("reviews:write", app.createReviewHandler))
+		r.Post("/movies/{id}/credits", app.requirePermission("movies:write", app.createCreditHandler))
+
+		r.Delete("/credits/{id}", app.requirePermission("movies:write", app.deleteCreditHandler))
+
+		r.Get("/genres", app.requirePermission("movies:read", app.listGenresHandler))
+		r.Post("/genres", app.requirePermission("genres:write", app.createGenreHandler))
+		r.Patch("/genres/{id}", app.requirePermission("genres:write", app.updateGenreHandler))
+
+		r.Get("/people", app.requirePermission("movies:read", app.listPeopleHandler))
+		r.Post("/people", app.requirePermission("movies:write", app.createPersonHandler))
+		r.Get("/people/{id}", app.requirePermission("movies:read", app.getPersonHandler))
+		r.Patch("/people/{id}", app.requirePermission("movies:write", app.updatePersonHandler))
+		r.Delete("/people/{id}", app.requirePermission("movies:write", app.deletePersonHandler))
 
+		r.Patch("/reviews/{id}", app.requirePermission("reviews:write", app.updateReviewHandler))
+		r.Delete("/reviews/{id}", app.requirePermission("reviews:write", app.deleteReviewHandler))
+
+		r.Get("/users", app.requirePermission("users:admin", app.listUsersHandler))
 		r.Post("/users", app.registerUserHandler)
 		r.Put("/users/activated", app.activateUserHandler)
+		r.Put("/users/password", app.updateUserPasswordHandler)
+		r.Get("/users/me", app.requireAuthenticatedUser(app.showCurrentUserHandler))
+		r.Patch("/users/me", app.requireAuthenticatedUser(app.rejectAPIKey(app.updateCurrentUserHandler)))
+		r.Delete("/users/me", app.requireAuthenticatedUser(app.rejectAPIKey(app.deleteCurrentUserHandler)))
+		r.Post("/users/me/2fa", app.requireActivatedUser(app.rejectAPIKey(app.startTwoFactorHandler)))
+		r.Put("/users/me/2fa", app.requireActivatedUser(app.rejectAPIKey(app.confirmTwoFactorHandler)))
+		r.Delete("/users/me/2fa", app.requireActivatedUser(app.rejectAPIKey(app.disableTwoFactorHandler)))
+		r.Get("/users/me/watchlist", app.requirePermission("movies:read", app.listWatchlistHandler))
+		r.Post("/users/me/watchlist", app.requirePermission("movies:read", app.addToWatchlistHandler))
+		r.Put("/users/me/watchlist/{id}", app.requirePermission("movies:read", app.moveWatchlistEntryHandler))
+		r.Delete("/users/me/watchlist/{id}", app.requirePermission("movies:read", app.removeFromWatchlistHandler))
+		r.Get("/users/me/history", app.requirePermission("movies:read", app.listHistoryHandler))
+		r.Post("/users/me/history", app.requirePermission("movies:read", app.createHistoryEntryHandler))
+		r.Delete("/users/me/history/{id}", app.requirePermission("movies:read", app.deleteHistoryEntryHandler))
+		r.Get("/users/me/api-keys", app.requireActivatedUser(app.rejectAPIKey(app.listAPIKeysHandler)))
+		r.Post("/users/me/api-keys", app.requireActivatedUser(app.rejectAPIKey(app.createAPIKeyHandler)))
+		r.Delete("/users/me/api-keys/{id}", app.requireActivatedUser(app.rejectAPIKey(app.deleteAPIKeyHandler)))
+		r.Patch("/users/{id}", app.requirePermission("users:admin", app.updateUserHandler))
+		r.Get("/users/{id}/permissions", app.requirePermission("users:admin", app.listUserPermissionsHandler))
+		r.Put("/users/{id}/permissions", app.requirePermission("users:admin", app.grantUserPermissionsHandler))
+		r.Delete("/users/{id}/permissions", app.requirePermission("users:admin", app.revokeUserPermissionsHandler))
+		r.Get("/users/{id}/roles", app.requirePermission("users:admin", app.listUserRolesHandler))
+		r.Put("/users/{id}/roles", app.requirePermission("users:admin", app.assignUserRolesHandler))
+		r.Delete("/users/{id}/roles", app.requirePermission("users:admin", app.unassignUserRolesHandler))
+
+		r.Get("/permissions", app.requirePermission("users:admin", app.listPermissionsHandler))
+		r.Get("/roles", app.requirePermission("users:admin", app.listRolesHandler))
 
+		r.Post("/tokens/activation", app.createActivationTokenHandler)
+		r.Get("/tokens", app.requireAuthenticatedUser(app.rejectAPIKey(app.listAuthenticationTokensHandler)))
 		r.Post("/tokens/authentication", app.createAuthenticationTokenHandler)
+		r.Post("/tokens/authentication/2fa", app.createTwoFactorAuthenticationTokenHandler)
+		r.Delete("/tokens/authentication", app.requireAuthenticatedUser(app.rejectAPIKey(app.deleteAuthenticationTokenHandler)))
+		r.Delete("/tokens/authentication/all", app.requireAuthenticatedUser(app.rejectAPIKey(app.deleteAllAuthenticationTokensHandler)))
+		r.Post("/tokens/password-reset", app.createPasswordResetTokenHandler)
+		r.Post("/tokens/refresh", app.refreshAuthenticationTokenHandler)
+
+		r.Get("/oauth/{provider}/start", app.startOAuthHandler)
+		r.Get("/oauth/{provider}/callback", app.oauthCallbackHandler)
 	})
 
 	return r
@@ -23,6 +23,10 @@ func (app *application) serve() error {
 	}
 
 	errCh := make(chan error)
+	done := make(chan struct{})
+
+	app.purgeDeletedMovies(done)
+	app.purgeLoginAttempts(done)
 
 	go func() {
 		quitCh := make(chan os.Signal, 1)
@@ -36,6 +40,8 @@ func (app *application) serve() error {
 
 		errCh <- srv.Shutdown(ctx)
 
+		close(done)
+
 		app.wg.Wait()
 		errCh <- nil
 	}()
@@ -3,8 +3,10 @@ package main
 import (
 	"errors"
 	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/jwt"
 	"github.com/makarellav/cinego/internal/validator"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -33,11 +35,31 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
+	ip, err := app.clientIP(r)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	if !app.checkLoginLockout(w, r, input.Email, ip) {
+		return
+	}
+
 	user, err := app.models.Users.GetByEmail(input.Email)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.recordFailedLogin(nil, input.Email, ip)
+
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+
+				return
+			}
+
 			app.invalidCredentialsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -55,12 +77,80 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	}
 
 	if !match {
+		err = app.recordFailedLogin(user, input.Email, ip)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
 		app.invalidCredentialsResponse(w, r)
 
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	err = app.resetFailedLogins(input.Email)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	// the plaintext password is only known here, so this is the chance to move the hash to the
+	// current algorithm and parameters. a failure shouldn't stop the user from logging in.
+	if user.Password.NeedsRehash(app.models.Users.Hashing) {
+		err = user.Password.Set(input.Password, app.models.Users.Hashing)
+
+		if err == nil {
+			err = app.models.Users.Update(user)
+		}
+
+		if err != nil {
+			app.logger.Error(err.Error(), "user_id", user.ID)
+		}
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// completeLogin finishes signing in a user whose first factor has been checked. Users with two-factor
+// authentication enabled get a challenge to answer instead of the tokens.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, user *data.User) {
+	tf, err := app.models.TwoFactor.Get(user.ID)
+
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	if tf != nil && tf.Enabled {
+		challenge, err := app.models.Tokens.New(user.ID, 5*time.Minute, data.ScopeTwoFactor)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusAccepted, envelope{"two_factor_required": true, "challenge_token": challenge}, nil)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	token, refreshToken, err := app.issueAuthenticationTokens(user, nil)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -68,9 +158,408 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"token": token}, nil)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"token": token, "refresh_token": refreshToken}, nil)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) refreshAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateToken(v, input.Token); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	refreshToken, err := app.models.Tokens.UseRefresh(input.Token)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTokenReused):
+			app.logger.Warn("refresh token reused, revoked its token family", "ip", r.RemoteAddr)
+			app.invalidAuthenticationTokenResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	user, err := app.models.Users.Get(refreshToken.UserID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+
+		return
+	}
+
+	token, newRefreshToken, err := app.issueAuthenticationTokens(user, refreshToken.Family)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"token": token, "refresh_token": newRefreshToken}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddKey("email", "no matching email address found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !user.Activated {
+		v.AddKey("email", "user account must be activated")
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	app.background(func() {
+		emailData := map[string]any{
+			"passwordResetToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "token_password_reset.gohtml", emailData)
+
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	message := "an email will be sent to you containing password reset instructions"
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": message}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddKey("email", "no matching email address found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if user.Activated {
+		v.AddKey("email", "user has already been activated")
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeActivation)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	app.background(func() {
+		emailData := map[string]any{
+			"activationToken": token.Plaintext,
+		}
+
+		err = app.mailer.Send(user.Email, "token_activation.gohtml", emailData)
+
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	message := "an email will be sent to you containing activation instructions"
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": message}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAuthenticationTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	tokens, err := app.models.Tokens.GetAllForUser(data.ScopeAuthentication, user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tokens": tokens}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := app.contextGetToken(r)
+
+	if !ok {
+		app.invalidAuthenticationTokenResponse(w, r)
+
+		return
+	}
+
+	scope := data.ScopeAuthentication
+
+	// a stateless token can't be revoked, so the session ends by revoking the refresh token that
+	// would renew it
+	if jwt.IsJWT(token) {
+		var input struct {
+			Token string `json:"token"`
+		}
+
+		err := app.readJSON(w, r, &input)
+
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+
+			return
+		}
+
+		v := validator.New()
+
+		if data.ValidateToken(v, input.Token); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+
+		scope = data.ScopeRefresh
+		token = input.Token
+	}
+
+	err := app.models.Tokens.DeleteFamily(scope, token, app.contextGetUser(r).ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been successfully logged out"}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAllAuthenticationTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.revokeSessions(user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been successfully logged out of all sessions"}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// issueAuthenticationTokens creates an access token and a refresh token that can later be exchanged
+// for a new pair. Tokens created by a refresh stay in the family of the original login.
+func (app *application) issueAuthenticationTokens(user *data.User, family []byte) (*data.Token, *data.Token, error) {
+	var err error
+
+	if family == nil {
+		family, err = app.models.Tokens.NewFamily()
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var token *data.Token
+
+	if app.jwt != nil {
+		token, err = app.newJWT(user)
+	} else {
+		token, err = app.models.Tokens.NewInFamily(user.ID, 24*time.Hour, data.ScopeAuthentication, family)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := app.models.Tokens.NewInFamily(user.ID, app.config.auth.refreshTTL, data.ScopeRefresh, family)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, refreshToken, nil
+}
+
+// revokeSessions signs the user out everywhere by removing all of their authentication and refresh
+// tokens.
+func (app *application) revokeSessions(userID int64) error {
+	err := app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return app.models.Tokens.DeleteAllForUser(data.ScopeRefresh, userID)
+}
+
+// newJWT issues a signed stateless authentication token. The user's permissions are embedded in the
+// token, so changes to them only take effect once the token expires.
+func (app *application) newJWT(user *data.User) (*data.Token, error) {
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiry := now.Add(app.config.auth.jwt.ttl)
+
+	claims := jwt.Claims{
+		Subject:     strconv.FormatInt(user.ID, 10),
+		IssuedAt:    now.Unix(),
+		NotBefore:   now.Unix(),
+		ExpiresAt:   expiry.Unix(),
+		Activated:   user.Activated,
+		Permissions: permissions,
+	}
+
+	signed, err := app.jwt.Sign(claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := data.Token{
+		Plaintext: signed,
+		UserID:    user.ID,
+		CreatedAt: now,
+		Expiry:    expiry,
+		Scope:     data.ScopeAuthentication,
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"github.com/makarellav/cinego/internal/data"
+	"github.com/makarellav/cinego/internal/totp"
+	"github.com/makarellav/cinego/internal/validator"
+	"net/http"
+	"time"
+)
+
+func (app *application) startTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.models.TwoFactor.Start(user.ID, secret)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTwoFactorEnabled):
+			v := validator.New()
+			v.AddKey("2fa", "two-factor authentication is already enabled")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	env := envelope{
+		"secret":           totp.EncodeSecret(secret),
+		"provisioning_uri": totp.ProvisioningURI("Cinego", user.Email, secret),
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTwoFactorCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	tf, err := app.models.TwoFactor.Get(user.ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddKey("2fa", "two-factor enrolment must be started first")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if tf.Enabled {
+		v.AddKey("2fa", "two-factor authentication is already enabled")
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	step, ok := totp.Validate(tf.Secret, input.Code, time.Now())
+
+	if !ok {
+		v.AddKey("code", "invalid or expired code")
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	_, err = app.models.TwoFactor.UseStep(user.ID, step)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	codes, err := data.GenerateRecoveryCodes(10)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.models.TwoFactor.Enable(user.ID, codes)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recovery_codes": codes}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTwoFactorCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	tf, err := app.models.TwoFactor.Get(user.ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if tf.Enabled {
+		ok, err := app.verifyTwoFactorCode(tf, input.Code)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		if !ok {
+			v.AddKey("code", "invalid or expired code")
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+	}
+
+	err = app.models.TwoFactor.Delete(user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "two-factor authentication successfully disabled"}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createTwoFactorAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateToken(v, input.Token)
+	data.ValidateTwoFactorCode(v, input.Code)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	user, err := app.models.Users.GetByToken(data.ScopeTwoFactor, input.Token)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	ip, err := app.clientIP(r)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	// the second step is guarded by the same lockout as the password, otherwise six digit codes could
+	// be guessed within the lifetime of a challenge
+	if !app.checkLoginLockout(w, r, user.Email, ip) {
+		return
+	}
+
+	tf, err := app.models.TwoFactor.Get(user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	ok, err := app.verifyTwoFactorCode(tf, input.Code)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	if !ok {
+		err = app.recordFailedLogin(user, user.Email, ip)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		app.invalidCredentialsResponse(w, r)
+
+		return
+	}
+
+	err = app.resetFailedLogins(user.Email)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeTwoFactor, user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+
+		return
+	}
+
+	token, refreshToken, err := app.issueAuthenticationTokens(user, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"token": token, "refresh_token": refreshToken}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// verifyTwoFactorCode accepts either a code from the authenticator app or one of the recovery codes.
+func (app *application) verifyTwoFactorCode(tf *data.TwoFactor, code string) (bool, error) {
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(tf.Secret, code, time.Now())
+
+		if !ok {
+			return false, nil
+		}
+
+		return app.models.TwoFactor.UseStep(tf.UserID, step)
+	}
+
+	return app.models.TwoFactor.UseRecoveryCode(tf.UserID, code)
+}
@@ -29,7 +29,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Activated: false,
 	}
 
-	err = user.Password.Set(input.Password)
+	err = user.Password.Set(input.Password, app.models.Users.Hashing)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -39,7 +39,17 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	v := validator.New()
 
-	if data.ValidateUser(v, &user); !v.Valid() {
+	data.ValidateUser(v, &user)
+
+	err = app.passwordPolicy.Validate(v, input.Password, &user)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 
 		return
@@ -59,7 +69,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Permissions.AddForUser(user.ID, "movies:read")
+	err = app.models.Permissions.AddForUser(user.ID, "movies:read", "reviews:write")
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -163,3 +173,498 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 }
+
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidatePasswordPlaintext(v, input.Password)
+	data.ValidateToken(v, input.Token)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	user, err := app.models.Users.GetByToken(data.ScopePasswordReset, input.Token)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddKey("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.passwordPolicy.Validate(v, input.Password, user)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	err = user.Password.Set(input.Password, app.models.Users.Hashing)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.models.Users.Update(user)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	// sign the user out everywhere, since any existing session may belong to whoever knew the old password
+	err = app.revokeSessions(user.ID)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input struct {
+		Name            *string `json:"name"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword *string `json:"current_password"`
+	}
+
+	err = app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+
+	emailChanged := input.Email != nil && *input.Email != user.Email
+
+	// a stolen token alone must not be enough to take the account over
+	if emailChanged || input.Password != nil {
+		if !app.checkCurrentPassword(w, r, user, input.CurrentPassword) {
+			return
+		}
+	}
+
+	if emailChanged {
+		user.Email = *input.Email
+		user.Activated = false
+	}
+
+	if input.Password != nil {
+		err = app.passwordPolicy.Validate(v, *input.Password, user)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+
+			return
+		}
+
+		err = user.Password.Set(*input.Password, app.models.Users.Hashing)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+	}
+
+	if data.ValidateUser(v, user); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	err = app.models.Users.Update(user)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddKey("email", "a user with this email already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if emailChanged {
+		err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeActivation)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+
+		app.background(func() {
+			emailData := map[string]any{
+				"activationToken": token.Plaintext,
+			}
+
+			err := app.mailer.Send(user.Email, "user_email_change.gohtml", emailData)
+
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input struct {
+		CurrentPassword *string `json:"current_password"`
+	}
+
+	err = app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	if !app.checkCurrentPassword(w, r, user, input.CurrentPassword) {
+		return
+	}
+
+	err = app.models.Users.Delete(user.ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your account was successfully deleted"}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkCurrentPassword sends an error response and returns false unless currentPassword is the
+// password of the user. Wrong passwords count towards the same lockout as failed logins, so that a
+// stolen token can't be used to guess the password.
+func (app *application) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user *data.User, currentPassword *string) bool {
+	v := validator.New()
+
+	if currentPassword == nil {
+		v.AddKey("current_password", "must be provided")
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return false
+	}
+
+	ip, err := app.clientIP(r)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return false
+	}
+
+	if !app.checkLoginLockout(w, r, user.Email, ip) {
+		return false
+	}
+
+	match, err := user.Password.Matches(*currentPassword)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return false
+	}
+
+	if !match {
+		err = app.recordFailedLogin(user, user.Email, ip)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return false
+		}
+
+		v.AddKey("current_password", "is incorrect")
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return false
+	}
+
+	err = app.resetFailedLogins(user.Email)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return false
+	}
+
+	return true
+}
+
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name          string
+		Email         string
+		Activated     *bool
+		CreatedAfter  *time.Time
+		CreatedBefore *time.Time
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Email = app.readString(qs, "email", "")
+	input.Activated = app.readBool(qs, "activated", nil, v)
+	input.CreatedAfter = app.readTime(qs, "created_after", nil, v)
+	input.CreatedBefore = app.readTime(qs, "created_before", nil, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+
+	if input.CreatedAfter != nil && input.CreatedBefore != nil {
+		v.Check(input.CreatedAfter.Before(*input.CreatedBefore), "created_before", "must be after created_after")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	users, metadata, err := app.models.Users.GetAll(input.Name, input.Email, input.Activated, input.CreatedAfter, input.CreatedBefore, input.Filters)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK,
+		envelope{
+			"metadata": metadata,
+			"users":    users,
+		}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+
+	if err != nil {
+		app.notFoundResponse(w, r)
+
+		return
+	}
+
+	user, err := app.models.Users.Get(id)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input struct {
+		Activated *bool `json:"activated"`
+		Locked    *bool `json:"locked"`
+	}
+
+	err = app.readJSON(w, r, &input)
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+
+		return
+	}
+
+	v := validator.New()
+
+	if input.Activated != nil {
+		user.Activated = *input.Activated
+	}
+
+	if input.Locked != nil {
+		v.Check(!*input.Locked || user.ID != app.contextGetUser(r).ID, "locked", "you cannot lock your own account")
+
+		user.Locked = *input.Locked
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	}
+
+	err = app.models.Users.Update(user)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if user.Locked {
+		err = app.revokeSessions(user.ID)
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -3,16 +3,20 @@ module github.com/makarellav/cinego
 go 1.22
 
 require (
-	github.com/go-chi/chi/v5 v5.0.12 // indirect
-	github.com/go-mail/mail/v2 v2.3.0 // indirect
+	github.com/go-chi/chi/v5 v5.0.12
+	github.com/go-mail/mail/v2 v2.3.0
+	github.com/jackc/pgx/v5 v5.5.3
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.17.0
+	golang.org/x/time v0.5.0
+)
+
+require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	github.com/jackc/pgx/v5 v5.5.3 // indirect
 	github.com/jackc/puddle/v2 v2.2.1 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
-	golang.org/x/time v0.5.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 )
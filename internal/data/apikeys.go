@@ -0,0 +1,219 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every API key so that they are easy to tell apart from other tokens, and easy
+// to spot when they leak.
+const APIKeyPrefix = "cg_"
+
+type APIKey struct {
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UserID      int64       `json:"-"`
+	Name        string      `json:"name"`
+	Plaintext   string      `json:"key,omitempty"`
+	Hash        []byte      `json:"-"`
+	Prefix      string      `json:"prefix"`
+	Permissions Permissions `json:"permissions"`
+	Expiry      *time.Time  `json:"expiry"`
+	LastUsedAt  *time.Time  `json:"last_used_at"`
+}
+
+type APIKeyModel struct {
+	DB *pgxpool.Pool
+}
+
+func generateAPIKey(key *APIKey) error {
+	randomBytes := make([]byte, 20)
+
+	_, err := rand.Read(randomBytes)
+
+	if err != nil {
+		return err
+	}
+
+	secret := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+
+	key.Plaintext = APIKeyPrefix + secret
+	key.Prefix = APIKeyPrefix + secret[:6]
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	return nil
+}
+
+// Insert generates the secret for key and stores it. The plaintext key is only available on the
+// returned struct, it can't be recovered afterwards.
+func (am *APIKeyModel) Insert(key *APIKey) error {
+	err := generateAPIKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_keys(user_id, name, hash, prefix, permissions, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	args := []any{key.UserID, key.Name, key.Hash, key.Prefix, key.Permissions, key.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return am.DB.QueryRow(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (am *APIKeyModel) GetByKey(plaintextKey string) (*APIKey, error) {
+	hash := sha256.Sum256([]byte(plaintextKey))
+
+	query := `
+		SELECT id, created_at, user_id, name, hash, prefix, permissions, expiry, last_used_at
+		FROM api_keys
+		WHERE hash = $1 AND (expiry IS NULL OR expiry > $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var key APIKey
+
+	err := am.DB.QueryRow(ctx, query, hash[:], time.Now()).Scan(
+		&key.ID,
+		&key.CreatedAt,
+		&key.UserID,
+		&key.Name,
+		&key.Hash,
+		&key.Prefix,
+		&key.Permissions,
+		&key.Expiry,
+		&key.LastUsedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &key, nil
+}
+
+func (am *APIKeyModel) GetAllForUser(userID int64) ([]*APIKey, error) {
+	query := `
+		SELECT id, created_at, user_id, name, hash, prefix, permissions, expiry, last_used_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := am.DB.Query(ctx, query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*APIKey, error) {
+		var key APIKey
+
+		err := row.Scan(
+			&key.ID,
+			&key.CreatedAt,
+			&key.UserID,
+			&key.Name,
+			&key.Hash,
+			&key.Prefix,
+			&key.Permissions,
+			&key.Expiry,
+			&key.LastUsedAt,
+		)
+
+		return &key, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// return an empty array instead of null if there are no results
+	if len(keys) == 0 {
+		return []*APIKey{}, nil
+	}
+
+	return keys, nil
+}
+
+// Touch records that the key was just used, at most once a minute.
+func (am *APIKeyModel) Touch(id int64) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1
+		AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := am.DB.Exec(ctx, query, id)
+
+	return err
+}
+
+func (am *APIKeyModel) Delete(id, userID int64) error {
+	query := `
+		DELETE FROM api_keys
+		WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := am.DB.Exec(ctx, query, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ValidateAPIKey checks a new key. The key may only carry permissions its owner already has.
+func ValidateAPIKey(v *validator.Validator, key *APIKey, ownerPermissions Permissions) {
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(key.Permissions) >= 1, "permissions", "must contain at least 1 permission code")
+	v.Check(validator.Unique(key.Permissions), "permissions", "must not contain duplicate values")
+
+	for _, code := range key.Permissions {
+		v.Check(ownerPermissions.Include(code), "permissions", "must only contain permissions you have")
+	}
+
+	if key.Expiry != nil {
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintextKey string) {
+	v.Check(strings.HasPrefix(plaintextKey, APIKeyPrefix), "key", "must be a valid API key")
+	v.Check(len(plaintextKey) == len(APIKeyPrefix)+32, "key", "must be a valid API key")
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"time"
+)
+
+// LoginAttempt tracks the failed logins for a single key, which is either an email address or a
+// client IP address.
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type LoginAttemptModel struct {
+	DB *pgxpool.Pool
+}
+
+func EmailLoginKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// IsLocked reports whether the key is locked out at the given time.
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
+
+func (lm *LoginAttemptModel) Get(key string) (*LoginAttempt, error) {
+	query := `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var attempt LoginAttempt
+
+	err := lm.DB.QueryRow(ctx, query, key).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure counts a failed login for the key. Failures older than window are forgotten, so the
+// count starts again from one.
+func (lm *LoginAttemptModel) RecordFailure(key string, window time.Duration) (*LoginAttempt, error) {
+	query := `
+		INSERT INTO login_attempts(key, failures, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failure_at < NOW() - $2::interval THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING key, failures, last_failure_at, locked_until`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var attempt LoginAttempt
+
+	err := lm.DB.QueryRow(ctx, query, key, window).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (lm *LoginAttemptModel) Lock(key string, until time.Time) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $2
+		WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := lm.DB.Exec(ctx, query, key, until)
+
+	return err
+}
+
+func (lm *LoginAttemptModel) Reset(keys ...string) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE key = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := lm.DB.Exec(ctx, query, keys)
+
+	return err
+}
+
+// DeleteExpired removes the keys that haven't failed within window and aren't locked out anymore,
+// as those would start counting from one again anyway.
+func (lm *LoginAttemptModel) DeleteExpired(window time.Duration) (int64, error) {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < NOW() - $1::interval
+		AND (locked_until IS NULL OR locked_until < NOW())`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := lm.DB.Exec(ctx, query, window)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type permissionsCacheEntry struct {
+	permissions Permissions
+	expiresAt   time.Time
+}
+
+// PermissionsCache keeps the permissions of recently seen users in memory for a limited time, so
+// that protected requests don't have to query the database every time.
+type PermissionsCache struct {
+	ttl       time.Duration
+	mu        sync.RWMutex
+	entries   map[int64]permissionsCacheEntry
+	lastSweep time.Time
+	hits      atomic.Int64
+	misses    atomic.Int64
+}
+
+func NewPermissionsCache(ttl time.Duration) *PermissionsCache {
+	return &PermissionsCache{
+		ttl:     ttl,
+		entries: make(map[int64]permissionsCacheEntry),
+	}
+}
+
+func (c *PermissionsCache) get(userID int64) (Permissions, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[userID]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		c.misses.Add(1)
+
+		return nil, false
+	}
+
+	c.hits.Add(1)
+
+	return entry.permissions, true
+}
+
+func (c *PermissionsCache) set(userID int64, permissions Permissions) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// drop expired entries from time to time so that users who stopped making requests don't linger
+	if now.Sub(c.lastSweep) > c.ttl {
+		for id, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+
+		c.lastSweep = now
+	}
+
+	c.entries[userID] = permissionsCacheEntry{
+		permissions: permissions,
+		expiresAt:   now.Add(c.ttl),
+	}
+}
+
+func (c *PermissionsCache) Invalidate(userID int64) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+func (c *PermissionsCache) Stats() map[string]int64 {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	return map[string]int64{
+		"hits":    c.hits.Load(),
+		"misses":  c.misses.Load(),
+		"entries": int64(entries),
+	}
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"strings"
+	"time"
+)
+
+const (
+	CreditDirector = "director"
+	CreditActor    = "actor"
+	CreditWriter   = "writer"
+)
+
+var ErrDuplicateCredit = errors.New("duplicate credit")
+
+type Credit struct {
+	ID        int64   `json:"id"`
+	MovieID   int64   `json:"-"`
+	Person    *Person `json:"person"`
+	Role      string  `json:"role"`
+	Character string  `json:"character,omitempty"`
+	Position  int32   `json:"position"`
+}
+
+type CreditModel struct {
+	DB *pgxpool.Pool
+}
+
+func (cm *CreditModel) Insert(credit *Credit) error {
+	query := `
+		INSERT INTO movie_credits(movie_id, person_id, role, character, position)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	args := []any{credit.MovieID, credit.Person.ID, credit.Role, credit.Character, credit.Position}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := cm.DB.QueryRow(ctx, query, args...).Scan(&credit.ID)
+
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), DuplicateKeyCode):
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllForMovie returns the credits of a movie, directors and writers first, then the cast in
+// billing order.
+func (cm *CreditModel) GetAllForMovie(movieID int64) ([]*Credit, error) {
+	query := `
+		SELECT movie_credits.id, movie_credits.role, movie_credits.character, movie_credits.position,
+			people.id, people.name, people.birth_date, people.version
+		FROM movie_credits
+		INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.movie_id = $1
+		ORDER BY CASE movie_credits.role WHEN 'director' THEN 0 WHEN 'writer' THEN 1 ELSE 2 END,
+			movie_credits.position, movie_credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := cm.DB.Query(ctx, query, movieID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credits, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Credit, error) {
+		credit := Credit{MovieID: movieID, Person: &Person{}}
+
+		err := row.Scan(&credit.ID,
+			&credit.Role,
+			&credit.Character,
+			&credit.Position,
+			&credit.Person.ID,
+			&credit.Person.Name,
+			&credit.Person.BirthDate,
+			&credit.Person.Version)
+
+		return &credit, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// return an empty array instead of null if there are no results
+	if len(credits) == 0 {
+		return []*Credit{}, nil
+	}
+
+	return credits, nil
+}
+
+func (cm *CreditModel) Delete(id int64) error {
+	query := `
+		DELETE FROM movie_credits WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := cm.DB.Exec(ctx, query, id)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.Person != nil && credit.Person.ID > 0, "person_id", "must be provided")
+
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(validator.PermittedValue(credit.Role, CreditDirector, CreditActor, CreditWriter), "role", "must be director, actor or writer")
+
+	v.Check(credit.Role == CreditActor || credit.Character == "", "character", "must only be provided for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+
+	v.Check(credit.Position >= 0, "position", "must not be negative")
+}
@@ -0,0 +1,258 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"strings"
+	"time"
+)
+
+var ErrDuplicateGenre = errors.New("duplicate genre")
+
+type Genre struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Name       string    `json:"name"`
+	MovieCount int64     `json:"movie_count"`
+	Version    int32     `json:"version"`
+}
+
+type GenreModel struct {
+	DB *pgxpool.Pool
+}
+
+func (gm *GenreModel) Insert(genre *Genre) error {
+	query := `
+		INSERT INTO genres(name)
+		VALUES ($1)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := gm.DB.QueryRow(ctx, query, genre.Name).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), DuplicateKeyCode):
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (gm *GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, (SELECT COUNT(*) FROM movies WHERE movies.genres @> ARRAY[genres.name] AND movies.deleted_at IS NULL), version
+		FROM genres
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var genre Genre
+
+	err := gm.DB.QueryRow(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Name,
+		&genre.MovieCount,
+		&genre.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+// GetAll returns every genre together with the number of movies in it.
+func (gm *GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT id, created_at, name, (SELECT COUNT(*) FROM movies WHERE movies.genres @> ARRAY[genres.name] AND movies.deleted_at IS NULL), version
+		FROM genres
+		ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := gm.DB.Query(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	genres, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Genre, error) {
+		var genre Genre
+
+		err := row.Scan(&genre.ID,
+			&genre.CreatedAt,
+			&genre.Name,
+			&genre.MovieCount,
+			&genre.Version)
+
+		return &genre, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// return an empty array instead of null if there are no results
+	if len(genres) == 0 {
+		return []*Genre{}, nil
+	}
+
+	return genres, nil
+}
+
+// GetAllNames returns the names of every genre, which is the vocabulary movies are validated against.
+func (gm *GenreModel) GetAllNames() ([]string, error) {
+	query := `
+		SELECT name FROM genres`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := gm.DB.Query(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// Update renames the genre, along with every movie that uses the old name. The change to each movie
+// is recorded as a revision for the user.
+func (gm *GenreModel) Update(genre *Genre, oldName string, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := gm.DB.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE genres
+		SET name = $1, version = version + 1
+		WHERE id = $2 AND version = $3
+		RETURNING version`
+
+	err = tx.QueryRow(ctx, query, genre.Name, genre.ID, genre.Version).Scan(&genre.Version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		case strings.Contains(err.Error(), DuplicateKeyCode):
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	if genre.Name != oldName {
+		err = renameMovieGenre(ctx, tx, oldName, genre.Name, userID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func renameMovieGenre(ctx context.Context, tx pgx.Tx, oldName, newName string, userID int64) error {
+	// lock the movies first, so that they can't change between reading and renaming them
+	_, err := tx.Exec(ctx, `SELECT id FROM movies WHERE genres @> ARRAY[$1] FOR UPDATE`, oldName)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM movies %s
+		WHERE movies.genres @> ARRAY[$1]`, movieColumns, movieRatingsJoin), oldName)
+
+	if err != nil {
+		return err
+	}
+
+	movies, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Movie, error) {
+		var movie Movie
+
+		err := row.Scan(movieFields(&movie)...)
+
+		return &movie, err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, before := range movies {
+		after := *before
+
+		err = tx.QueryRow(ctx, `
+			UPDATE movies
+			SET genres = array_replace(genres, $1, $2), version = version + 1
+			WHERE id = $3
+			RETURNING genres, version`, oldName, newName, before.ID).Scan(&after.Genres, &after.Version)
+
+		if err != nil {
+			return err
+		}
+
+		err = recordRevision(ctx, tx, RevisionUpdate, userID, before, &after)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NormalizeGenres brings genre names given by clients into the lowercase form genres are stored in.
+func NormalizeGenres(names []string) []string {
+	if names == nil {
+		return nil
+	}
+
+	normalized := make([]string, len(names))
+
+	for i, name := range names {
+		normalized[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+
+	return normalized
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 50, "name", "must not be more than 50 bytes long")
+	v.Check(genre.Name == strings.ToLower(strings.TrimSpace(genre.Name)), "name", "must be lowercase without surrounding spaces")
+}
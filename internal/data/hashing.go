@@ -0,0 +1,181 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// HashParams configures how new password hashes are created. Hashes are stored in an encoded format
+// that carries their own parameters (the usual $2a$ format for bcrypt and the PHC string format for
+// argon2id), so existing hashes keep working when the parameters change.
+type HashParams struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// DefaultHashParams returns the parameters used for new password hashes unless configured otherwise.
+func DefaultHashParams() HashParams {
+	return HashParams{
+		Algorithm:         HashBcrypt,
+		BcryptCost:        bcrypt.DefaultCost,
+		Argon2Memory:      64 * 1024,
+		Argon2Iterations:  3,
+		Argon2Parallelism: 2,
+	}
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+type argon2Hash struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (hp HashParams) Validate() error {
+	switch hp.Algorithm {
+	case HashBcrypt:
+		if hp.BcryptCost < bcrypt.MinCost || hp.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashArgon2id:
+		if hp.Argon2Memory < 8*uint32(hp.Argon2Parallelism) || hp.Argon2Iterations < 1 || hp.Argon2Parallelism < 1 {
+			return errors.New("argon2id needs at least 1 iteration, 1 thread and 8KiB of memory per thread")
+		}
+	default:
+		return fmt.Errorf("unsupported password hash algorithm %q", hp.Algorithm)
+	}
+
+	return nil
+}
+
+func (hp HashParams) hash(plaintextPassword string) ([]byte, error) {
+	if hp.Algorithm == HashArgon2id {
+		salt := make([]byte, argon2SaltLength)
+
+		_, err := rand.Read(salt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key := argon2.IDKey([]byte(plaintextPassword), salt, hp.Argon2Iterations, hp.Argon2Memory, hp.Argon2Parallelism, argon2KeyLength)
+
+		encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			hp.Argon2Memory,
+			hp.Argon2Iterations,
+			hp.Argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key))
+
+		return []byte(encoded), nil
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(plaintextPassword), hp.BcryptCost)
+}
+
+// outdated reports whether hash was created with a different algorithm or different parameters.
+func (hp HashParams) outdated(hash []byte) bool {
+	if h, err := parseArgon2Hash(hash); err == nil {
+		return hp.Algorithm != HashArgon2id ||
+			h.memory != hp.Argon2Memory ||
+			h.iterations != hp.Argon2Iterations ||
+			h.parallelism != hp.Argon2Parallelism
+	}
+
+	cost, err := bcrypt.Cost(hash)
+
+	if err != nil {
+		return true
+	}
+
+	return hp.Algorithm != HashBcrypt || cost != hp.BcryptCost
+}
+
+func compareHash(hash []byte, plaintextPassword string) (bool, error) {
+	if !strings.HasPrefix(string(hash), "$argon2id$") {
+		err := bcrypt.CompareHashAndPassword(hash, []byte(plaintextPassword))
+
+		if err != nil {
+			switch {
+			case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+				return false, nil
+			default:
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+
+	h, err := parseArgon2Hash(hash)
+
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(plaintextPassword), h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+
+	return subtle.ConstantTimeCompare(key, h.key) == 1, nil
+}
+
+func parseArgon2Hash(hash []byte) (*argon2Hash, error) {
+	parts := strings.Split(string(hash), "$")
+
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return nil, ErrInvalidHash
+	}
+
+	var version int
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+
+	if err != nil || version != argon2.Version {
+		return nil, ErrInvalidHash
+	}
+
+	var h argon2Hash
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism)
+
+	// argon2 panics on zero iterations or threads
+	if err != nil || h.iterations < 1 || h.parallelism < 1 {
+		return nil, ErrInvalidHash
+	}
+
+	h.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+
+	h.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil || len(h.key) == 0 {
+		return nil, ErrInvalidHash
+	}
+
+	return &h, nil
+}
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestParseArgon2Hash(t *testing.T) {
+	const (
+		salt = "c2FsdHNhbHRzYWx0c2FsdA"
+		key  = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	)
+
+	tests := []struct {
+		name  string
+		hash  string
+		valid bool
+	}{
+		{"valid", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key, true},
+		{"other algorithm", "$argon2i$v=19$m=65536,t=3,p=2$" + salt + "$" + key, false},
+		{"other version", "$argon2id$v=16$m=65536,t=3,p=2$" + salt + "$" + key, false},
+		{"missing key", "$argon2id$v=19$m=65536,t=3,p=2$" + salt, false},
+		{"empty key", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$", false},
+		{"bad salt encoding", "$argon2id$v=19$m=65536,t=3,p=2$!!!$" + key, false},
+		{"bad parameters", "$argon2id$v=19$memory=65536$" + salt + "$" + key, false},
+		{"zero iterations", "$argon2id$v=19$m=65536,t=0,p=2$" + salt + "$" + key, false},
+		{"zero threads", "$argon2id$v=19$m=65536,t=3,p=0$" + salt + "$" + key, false},
+		{"threads overflow", "$argon2id$v=19$m=65536,t=3,p=256$" + salt + "$" + key, false},
+		{"bcrypt", "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := parseArgon2Hash([]byte(tt.hash))
+
+			switch {
+			case tt.valid && err != nil:
+				t.Fatalf("got error %v", err)
+			case !tt.valid && !errors.Is(err, ErrInvalidHash):
+				t.Fatalf("got error %v, want ErrInvalidHash", err)
+			case !tt.valid:
+				return
+			}
+
+			if h.memory != 65536 || h.iterations != 3 || h.parallelism != 2 {
+				t.Errorf("got m=%d,t=%d,p=%d, want m=65536,t=3,p=2", h.memory, h.iterations, h.parallelism)
+			}
+
+			if !bytes.Equal(h.salt, []byte("saltsaltsaltsalt")) || len(h.key) != 29 {
+				t.Errorf("got salt %q and a key of %d bytes", h.salt, len(h.key))
+			}
+		})
+	}
+}
+
+func TestPasswordRoundTrip(t *testing.T) {
+	bcryptParams := HashParams{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost}
+	argon2Params := HashParams{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1}
+
+	tests := []struct {
+		name   string
+		params HashParams
+		// newer are different parameters, for which the hash needs rehashing
+		newer []HashParams
+	}{
+		{
+			name:   "bcrypt",
+			params: bcryptParams,
+			newer: []HashParams{
+				{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost + 1},
+				argon2Params,
+			},
+		},
+		{
+			name:   "argon2id",
+			params: argon2Params,
+			newer: []HashParams{
+				{Algorithm: HashArgon2id, Argon2Memory: 128, Argon2Iterations: 1, Argon2Parallelism: 1},
+				{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 2, Argon2Parallelism: 1},
+				{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 2},
+				bcryptParams,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p password
+
+			err := p.Set("pa55word-secret", tt.params)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for plaintext, want := range map[string]bool{"pa55word-secret": true, "pa55word-secreT": false, "": false} {
+				match, err := p.Matches(plaintext)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if match != want {
+					t.Errorf("Matches(%q) = %v, want %v", plaintext, match, want)
+				}
+			}
+
+			if p.NeedsRehash(tt.params) {
+				t.Error("hash needs rehashing with the parameters it was created with")
+			}
+
+			for _, params := range tt.newer {
+				if !p.NeedsRehash(params) {
+					t.Errorf("hash doesn't need rehashing for %+v", params)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareMalformedArgon2Hash(t *testing.T) {
+	_, err := compareHash([]byte("$argon2id$v=19$m=65536,t=3,p=0$c2FsdA$a2V5"), "pa55word")
+
+	if !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("got error %v, want ErrInvalidHash", err)
+	}
+}
+
+func TestHashParamsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params HashParams
+		valid  bool
+	}{
+		{"defaults", DefaultHashParams(), true},
+		{"bcrypt cost too low", HashParams{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost - 1}, false},
+		{"bcrypt cost too high", HashParams{Algorithm: HashBcrypt, BcryptCost: bcrypt.MaxCost + 1}, false},
+		{"argon2id", HashParams{Algorithm: HashArgon2id, Argon2Memory: 16, Argon2Iterations: 1, Argon2Parallelism: 2}, true},
+		{"argon2id too little memory", HashParams{Algorithm: HashArgon2id, Argon2Memory: 15, Argon2Iterations: 1, Argon2Parallelism: 2}, false},
+		{"argon2id no iterations", HashParams{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Parallelism: 1}, false},
+		{"argon2id no threads", HashParams{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1}, false},
+		{"unknown algorithm", HashParams{Algorithm: "scrypt"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
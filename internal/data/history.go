@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"time"
+)
+
+type HistoryEntry struct {
+	ID        int64     `json:"id"`
+	WatchedAt time.Time `json:"watched_at"`
+	Rating    *int16    `json:"rating,omitempty"`
+	UserID    int64     `json:"-"`
+	MovieID   int64     `json:"-"`
+	Movie     *Movie    `json:"movie,omitempty"`
+}
+
+type HistoryModel struct {
+	DB *pgxpool.Pool
+}
+
+func (hm *HistoryModel) Insert(entry *HistoryEntry) error {
+	query := `
+		INSERT INTO watch_history(user_id, movie_id, watched_at, rating)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	args := []any{entry.UserID, entry.MovieID, entry.WatchedAt, entry.Rating}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return hm.DB.QueryRow(ctx, query, args...).Scan(&entry.ID)
+}
+
+func (hm *HistoryModel) GetAll(userID int64, movieID int64, filters Filters) ([]*HistoryEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), watch_history.id, watch_history.watched_at, watch_history.rating, %s
+		FROM watch_history
+		INNER JOIN movies ON movies.id = watch_history.movie_id %s
+		WHERE watch_history.user_id = $1 AND movies.deleted_at IS NULL
+		AND (watch_history.movie_id = $2 OR $2 = 0)
+		ORDER BY %s %s, watch_history.id DESC
+		LIMIT $3 OFFSET $4`, movieColumns, movieRatingsJoin, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := hm.DB.Query(ctx, query, userID, movieID, filters.limit(), filters.offset())
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var totalRecords int
+
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*HistoryEntry, error) {
+		entry := HistoryEntry{UserID: userID, Movie: &Movie{}}
+
+		err := row.Scan(append([]any{&totalRecords, &entry.ID, &entry.WatchedAt, &entry.Rating}, movieFields(entry.Movie)...)...)
+
+		entry.MovieID = entry.Movie.ID
+
+		return &entry, err
+	})
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(entries) == 0 {
+		return []*HistoryEntry{}, Metadata{}, nil
+	}
+
+	return entries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (hm *HistoryModel) Delete(id int64, userID int64) error {
+	query := `
+		DELETE FROM watch_history
+		WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := hm.DB.Exec(ctx, query, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func ValidateHistoryEntry(v *validator.Validator, entry *HistoryEntry) {
+	v.Check(entry.MovieID > 0, "movie_id", "must be provided")
+
+	v.Check(!entry.WatchedAt.IsZero(), "watched_at", "must be provided")
+	v.Check(entry.WatchedAt.Before(time.Now().Add(24*time.Hour)), "watched_at", "must not be in the future")
+
+	if entry.Rating != nil {
+		v.Check(*entry.Rating >= 1 && *entry.Rating <= 10, "rating", "must be between 1 and 10")
+	}
+}
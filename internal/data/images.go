@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+const (
+	ImagePoster   = "poster"
+	ImageBackdrop = "backdrop"
+)
+
+// MovieImage describes an uploaded image. The image itself, and its thumbnails, live in storage
+// below KeyPrefix.
+type MovieImage struct {
+	MovieID     int64             `json:"-"`
+	Kind        string            `json:"kind"`
+	KeyPrefix   string            `json:"-"`
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	URLs        map[string]string `json:"urls,omitempty"`
+}
+
+type MovieImageModel struct {
+	DB *pgxpool.Pool
+}
+
+func (mm *MovieImageModel) Get(movieID int64, kind string) (*MovieImage, error) {
+	query := `
+		SELECT movie_images.movie_id, movie_images.kind, movie_images.key_prefix, movie_images.content_type,
+			movie_images.width, movie_images.height, movie_images.updated_at
+		FROM movie_images
+		INNER JOIN movies ON movies.id = movie_images.movie_id
+		WHERE movie_images.movie_id = $1 AND movie_images.kind = $2 AND movies.deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var image MovieImage
+
+	err := mm.DB.QueryRow(ctx, query, movieID, kind).Scan(
+		&image.MovieID,
+		&image.Kind,
+		&image.KeyPrefix,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.UpdatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
+
+// Replace stores the image, replacing any previous image of the same kind. It returns the key prefix
+// of the replaced image, or an empty string if there was none, so that its objects can be removed.
+func (mm *MovieImageModel) Replace(image *MovieImage) (string, error) {
+	query := `
+		WITH previous AS (
+			SELECT key_prefix FROM movie_images WHERE movie_id = $1 AND kind = $2
+		)
+		INSERT INTO movie_images(movie_id, kind, key_prefix, content_type, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (movie_id, kind) DO UPDATE
+		SET key_prefix = EXCLUDED.key_prefix, content_type = EXCLUDED.content_type,
+			width = EXCLUDED.width, height = EXCLUDED.height, updated_at = NOW()
+		RETURNING updated_at, COALESCE((SELECT key_prefix FROM previous), '')`
+
+	args := []any{image.MovieID, image.Kind, image.KeyPrefix, image.ContentType, image.Width, image.Height}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var previous string
+
+	err := mm.DB.QueryRow(ctx, query, args...).Scan(&image.UpdatedAt, &previous)
+
+	return previous, err
+}
+
+// Delete removes the image and returns it, so that its objects can be removed from storage.
+func (mm *MovieImageModel) Delete(movieID int64, kind string) (*MovieImage, error) {
+	query := `
+		DELETE FROM movie_images
+		USING movies
+		WHERE movies.id = movie_images.movie_id AND movie_images.movie_id = $1 AND movie_images.kind = $2
+		AND movies.deleted_at IS NULL
+		RETURNING movie_images.key_prefix, movie_images.content_type`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	image := MovieImage{MovieID: movieID, Kind: kind}
+
+	err := mm.DB.QueryRow(ctx, query, movieID, kind).Scan(&image.KeyPrefix, &image.ContentType)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"errors"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 var (
@@ -15,13 +16,49 @@ type Models struct {
 	Users       UserModel
 	Tokens      TokenModel
 	Permissions PermissionsModel
+	Roles       RoleModel
+	Logins      LoginAttemptModel
+	TwoFactor   TwoFactorModel
+	APIKeys     APIKeyModel
+	OAuthStates OAuthStateModel
+	Identities  IdentityModel
+	Reviews     ReviewModel
+	Watchlist   WatchlistModel
+	History     HistoryModel
+	People      PersonModel
+	Credits     CreditModel
+	Genres      GenreModel
+	Images      MovieImageModel
+	Revisions   MovieRevisionModel
 }
 
-func NewModels(db *pgxpool.Pool) *Models {
+// NewModels sets up the models. Permissions are cached in memory for permissionsTTL, a zero TTL
+// disables the cache. New passwords are hashed with hashing.
+func NewModels(db *pgxpool.Pool, permissionsTTL time.Duration, hashing HashParams) *Models {
+	var cache *PermissionsCache
+
+	if permissionsTTL > 0 {
+		cache = NewPermissionsCache(permissionsTTL)
+	}
+
 	return &Models{
 		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
+		Users:       UserModel{DB: db, Hashing: hashing},
 		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionsModel{DB: db},
+		Permissions: PermissionsModel{DB: db, Cache: cache},
+		Roles:       RoleModel{DB: db, Cache: cache},
+		Logins:      LoginAttemptModel{DB: db},
+		TwoFactor:   TwoFactorModel{DB: db},
+		APIKeys:     APIKeyModel{DB: db},
+		OAuthStates: OAuthStateModel{DB: db},
+		Identities:  IdentityModel{DB: db},
+		Reviews:     ReviewModel{DB: db},
+		Watchlist:   WatchlistModel{DB: db},
+		History:     HistoryModel{DB: db},
+		People:      PersonModel{DB: db},
+		Credits:     CreditModel{DB: db},
+		Genres:      GenreModel{DB: db},
+		Images:      MovieImageModel{DB: db},
+		Revisions:   MovieRevisionModel{DB: db},
 	}
 }
@@ -7,35 +7,142 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/makarellav/cinego/internal/validator"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
+var (
+	ErrDuplicateIMDbID = errors.New("duplicate imdb id")
+	ErrDuplicateTMDBID = errors.New("duplicate tmdb id")
+
+	IMDbIDRX = regexp.MustCompile("^tt[0-9]{7,10}$")
+)
+
 type Movie struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	ID                  int64             `json:"id"`
+	CreatedAt           time.Time         `json:"-"`
+	Title               string            `json:"title"`
+	OriginalTitle       string            `json:"original_title,omitempty"`
+	Synopsis            string            `json:"synopsis,omitempty"`
+	Year                int32             `json:"year,omitempty"`
+	ReleaseDate         *time.Time        `json:"release_date,omitempty"`
+	Runtime             Runtime           `json:"runtime,omitempty"`
+	Genres              []string          `json:"genres,omitempty"`
+	SpokenLanguages     []string          `json:"spoken_languages,omitempty"`
+	ProductionCountries []string          `json:"production_countries,omitempty"`
+	Certifications      map[string]string `json:"certifications,omitempty"`
+	IMDbID              *string           `json:"imdb_id,omitempty"`
+	TMDBID              *int64            `json:"tmdb_id,omitempty"`
+	AverageRating       float64           `json:"average_rating"`
+	ReviewCount         int64             `json:"review_count"`
+	DeletedAt           *time.Time        `json:"deleted_at,omitempty"`
+	Version             int32             `json:"version"`
+}
+
+// movieColumns are the columns of a movie in the order movieFields scans them. They need the
+// ratings from movieRatingsJoin.
+const movieColumns = `movies.id, movies.created_at, movies.title, movies.original_title, movies.synopsis,
+			movies.year, movies.release_date, movies.runtime, movies.genres, movies.spoken_languages,
+			movies.production_countries, movies.certifications, movies.imdb_id, movies.tmdb_id,
+			COALESCE(r.average_rating, 0) AS average_rating, r.review_count, movies.deleted_at, movies.version`
+
+// movieRatingsJoin joins the average rating and the number of reviews of each row from movies as r.
+const movieRatingsJoin = `
+		LEFT JOIN LATERAL (
+			SELECT AVG(rating)::float8 AS average_rating, COUNT(*) AS review_count
+			FROM reviews
+			WHERE reviews.movie_id = movies.id
+		) r ON true`
+
+func movieFields(movie *Movie) []any {
+	return []any{
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.OriginalTitle,
+		&movie.Synopsis,
+		&movie.Year,
+		&movie.ReleaseDate,
+		&movie.Runtime,
+		&movie.Genres,
+		&movie.SpokenLanguages,
+		&movie.ProductionCountries,
+		&movie.Certifications,
+		&movie.IMDbID,
+		&movie.TMDBID,
+		&movie.AverageRating,
+		&movie.ReviewCount,
+		&movie.DeletedAt,
+		&movie.Version,
+	}
+}
+
+// movieWriteError translates the unique violations of the external IDs.
+func movieWriteError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), DuplicateKeyCode) && strings.Contains(err.Error(), "movies_imdb_id_key"):
+		return ErrDuplicateIMDbID
+	case strings.Contains(err.Error(), DuplicateKeyCode) && strings.Contains(err.Error(), "movies_tmdb_id_key"):
+		return ErrDuplicateTMDBID
+	default:
+		return err
+	}
 }
 
 type MovieModel struct {
 	DB *pgxpool.Pool
 }
 
-func (m *MovieModel) Insert(movie *Movie) error {
+// Insert adds the movie, recording its first revision for the user.
+func (m *MovieModel) Insert(movie *Movie, userID int64) error {
 	query := `
-		INSERT INTO movies(title, year, runtime, genres)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO movies(title, original_title, synopsis, year, release_date, runtime, genres,
+			spoken_languages, production_countries, certifications, imdb_id, tmdb_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, '{}'::text[]), COALESCE($9, '{}'::text[]),
+			COALESCE($10, '{}'::jsonb), $11, $12)
 		RETURNING id, created_at, version`
 
-	args := []any{movie.Title, movie.Year, movie.Runtime, movie.Genres}
+	args := []any{
+		movie.Title,
+		movie.OriginalTitle,
+		movie.Synopsis,
+		movie.Year,
+		movie.ReleaseDate,
+		movie.Runtime,
+		movie.Genres,
+		movie.SpokenLanguages,
+		movie.ProductionCountries,
+		movie.Certifications,
+		movie.IMDbID,
+		movie.TMDBID,
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRow(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	tx, err := m.DB.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+
+	if err != nil {
+		return movieWriteError(err)
+	}
+
+	err = recordRevision(ctx, tx, RevisionInsert, userID, nil, movie)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (m *MovieModel) Get(id int64) (*Movie, error) {
@@ -43,25 +150,49 @@ func (m *MovieModel) Get(id int64) (*Movie, error) {
 		return nil, ErrRecordNotFound
 	}
 
-	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
-		FROM movies
-		WHERE id = $1`
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM movies %s
+		WHERE movies.id = $1 AND movies.deleted_at IS NULL`, movieColumns, movieRatingsJoin)
+
+	return m.getBy(query, id)
+}
+
+// GetByExternalID looks a movie up by its IMDb ID or, if imdbID is empty, by its TMDB ID.
+func (m *MovieModel) GetByExternalID(imdbID string, tmdbID int64) (*Movie, error) {
+	if imdbID != "" {
+		query := fmt.Sprintf(`
+			SELECT %s
+			FROM movies %s
+			WHERE movies.imdb_id = $1 AND movies.deleted_at IS NULL`, movieColumns, movieRatingsJoin)
 
+		return m.getBy(query, imdbID)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM movies %s
+		WHERE movies.tmdb_id = $1 AND movies.deleted_at IS NULL`, movieColumns, movieRatingsJoin)
+
+	return m.getBy(query, tmdbID)
+}
+
+func (m *MovieModel) getBy(query string, arg any) (*Movie, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	return getMovie(ctx, m.DB, query, arg)
+}
+
+// rowQuerier is either the pool or a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getMovie(ctx context.Context, q rowQuerier, query string, arg any) (*Movie, error) {
 	var movie Movie
 
-	err := m.DB.QueryRow(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		&movie.Genres,
-		&movie.Version,
-	)
+	err := q.QueryRow(ctx, query, arg).Scan(movieFields(&movie)...)
 
 	if err != nil {
 		switch {
@@ -75,19 +206,28 @@ func (m *MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+func (m *MovieModel) GetAll(title string, genres []string, director string, actor string, filters Filters) ([]*Movie, Metadata, error) {
+	sortColumn := filters.sortColumn()
+
+	if sortColumn == "rating" {
+		sortColumn = "average_rating"
+	}
+
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), id, created_at, title, year, runtime, genres, version
-		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
+		SELECT COUNT(*) OVER(), %s
+		FROM movies %s
+		WHERE movies.deleted_at IS NULL
+		AND (to_tsvector('simple', movies.title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (movies.genres @> $2 OR $2 = '{}')
+		AND ($3 = '' OR EXISTS (%s))
+		AND ($4 = '' OR EXISTS (%s))
 		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		LIMIT $5 OFFSET $6`, movieColumns, movieRatingsJoin, creditedPersonQuery(CreditDirector, 3), creditedPersonQuery(CreditActor, 4), sortColumn, filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	args := []any{title, genres, filters.limit(), filters.offset()}
+	args := []any{title, genres, director, actor, filters.limit(), filters.offset()}
 
 	rows, err := m.DB.Query(ctx, query, args...)
 
@@ -100,14 +240,7 @@ func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*
 	movies, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Movie, error) {
 		var movie Movie
 
-		err := row.Scan(&totalRecords,
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			&movie.Genres,
-			&movie.Version)
+		err := row.Scan(append([]any{&totalRecords}, movieFields(&movie)...)...)
 
 		return &movie, err
 	})
@@ -124,53 +257,275 @@ func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*
 	return movies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
 }
 
-func (m *MovieModel) Update(movie *Movie) error {
+// creditedPersonQuery matches the movies that credit a person with the given role whose name matches
+// the query argument with index arg.
+func creditedPersonQuery(role string, arg int) string {
+	return fmt.Sprintf(`
+			SELECT 1
+			FROM movie_credits
+			INNER JOIN people ON people.id = movie_credits.person_id
+			WHERE movie_credits.movie_id = movies.id
+			AND movie_credits.role = '%s'
+			AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', $%d)`, role, arg)
+}
+
+// Update saves the changes to the movie, which was before as it was read, and records them as a
+// revision for the user.
+func (m *MovieModel) Update(before, movie *Movie, userID int64) error {
+	return m.update(before, movie, RevisionUpdate, userID)
+}
+
+// Revert is an Update that puts the movie back to an earlier revision.
+func (m *MovieModel) Revert(before, movie *Movie, userID int64) error {
+	return m.update(before, movie, RevisionRevert, userID)
+}
+
+func (m *MovieModel) update(before, movie *Movie, action string, userID int64) error {
 	query := `
 		UPDATE movies 
-		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		SET title = $1, original_title = $2, synopsis = $3, year = $4, release_date = $5, runtime = $6,
+			genres = $7, spoken_languages = COALESCE($8, '{}'::text[]), production_countries = COALESCE($9, '{}'::text[]),
+			certifications = COALESCE($10, '{}'::jsonb),
+			imdb_id = $11, tmdb_id = $12, version = version + 1
+		WHERE id = $13 AND version = $14 AND deleted_at IS NULL
 		RETURNING version`
 
-	args := []any{movie.Title, movie.Year, movie.Runtime, movie.Genres, movie.ID, movie.Version}
+	args := []any{
+		movie.Title,
+		movie.OriginalTitle,
+		movie.Synopsis,
+		movie.Year,
+		movie.ReleaseDate,
+		movie.Runtime,
+		movie.Genres,
+		movie.SpokenLanguages,
+		movie.ProductionCountries,
+		movie.Certifications,
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.ID,
+		movie.Version,
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRow(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&movie.Version)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, pgx.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return movieWriteError(err)
 		}
 	}
 
-	return nil
+	err = recordRevision(ctx, tx, action, userID, before, movie)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (m *MovieModel) Delete(id int64) error {
+// Delete moves the movie to the trash, recording it as a revision for the user. It stays there until
+// it is restored or purged.
+func (m *MovieModel) Delete(movie *Movie, userID int64) error {
 	query := `
-		DELETE FROM movies WHERE id = $1`
+		UPDATE movies
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		RETURNING deleted_at, version`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	result, err := m.DB.Exec(ctx, query, id)
+	tx, err := m.DB.Begin(ctx)
 
 	if err != nil {
 		return err
 	}
 
+	defer tx.Rollback(ctx)
+
+	before := *movie
+
+	err = tx.QueryRow(ctx, query, movie.ID, movie.Version).Scan(&movie.DeletedAt, &movie.Version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, RevisionDelete, userID, &before, movie)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetAllDeleted lists the movies in the trash.
+func (m *MovieModel) GetAllDeleted(filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), %s
+		FROM movies %s
+		WHERE movies.deleted_at IS NOT NULL
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, movieColumns, movieRatingsJoin, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, filters.limit(), filters.offset())
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var totalRecords int
+
+	movies, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Movie, error) {
+		var movie Movie
+
+		err := row.Scan(append([]any{&totalRecords}, movieFields(&movie)...)...)
+
+		return &movie, err
+	})
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(movies) == 0 {
+		return []*Movie{}, Metadata{}, nil
+	}
+
+	return movies, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Restore takes the movie out of the trash, recording it as a revision for the user.
+func (m *MovieModel) Restore(id int64, userID int64) (*Movie, error) {
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, id)
+
+	if err != nil {
+		// another movie may have taken the external IDs while this one was in the trash
+		return nil, movieWriteError(err)
+	}
+
 	if result.RowsAffected() == 0 {
-		return ErrRecordNotFound
+		return nil, ErrRecordNotFound
+	}
+
+	movie, err := getMovie(ctx, tx, fmt.Sprintf(`
+		SELECT %s
+		FROM movies %s
+		WHERE movies.id = $1`, movieColumns, movieRatingsJoin), id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordRevision(ctx, tx, RevisionRestore, userID, movie, movie)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return movie, nil
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// Purge permanently removes the movies that have been in the trash for longer than retention. It
+// returns the images of the removed movies, so that they can be removed from storage too.
+func (m *MovieModel) Purge(retention time.Duration) (int64, []*MovieImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctx)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT movie_images.movie_id, movie_images.kind, movie_images.key_prefix
+		FROM movie_images
+		INNER JOIN movies ON movies.id = movie_images.movie_id
+		WHERE movies.deleted_at < NOW() - $1::interval
+		FOR UPDATE OF movies`, retention)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	images, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*MovieImage, error) {
+		var image MovieImage
+
+		err := row.Scan(&image.MovieID, &image.Kind, &image.KeyPrefix)
+
+		return &image, err
+	})
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM movies WHERE deleted_at < NOW() - $1::interval`, retention)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return result.RowsAffected(), images, nil
+}
+
+// ValidateMovie checks movie, including that its genres are part of the genres vocabulary.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres []string) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 
@@ -185,4 +540,43 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+
+	for _, genre := range movie.Genres {
+		v.Check(slices.Contains(genres, genre), "genres", fmt.Sprintf("unknown genre %q", genre))
+	}
+
+	v.Check(len(movie.OriginalTitle) <= 500, "original_title", "must not be more than 500 bytes long")
+	v.Check(len(movie.Synopsis) <= 10_000, "synopsis", "must not be more than 10000 bytes long")
+
+	if movie.ReleaseDate != nil {
+		v.Check(movie.ReleaseDate.Year() >= 1888, "release_date", "must not be before 1888")
+	}
+
+	v.Check(len(movie.SpokenLanguages) <= 20, "spoken_languages", "must not contain more than 20 languages")
+	v.Check(validator.Unique(movie.SpokenLanguages), "spoken_languages", "must not contain duplicate values")
+
+	for _, code := range movie.SpokenLanguages {
+		v.Check(languageCodes[code], "spoken_languages", fmt.Sprintf("%q is not an ISO 639-1 language code", code))
+	}
+
+	v.Check(len(movie.ProductionCountries) <= 20, "production_countries", "must not contain more than 20 countries")
+	v.Check(validator.Unique(movie.ProductionCountries), "production_countries", "must not contain duplicate values")
+
+	for _, code := range movie.ProductionCountries {
+		v.Check(countryCodes[code], "production_countries", fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 country code", code))
+	}
+
+	for country, certification := range movie.Certifications {
+		v.Check(countryCodes[country], "certifications", fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 country code", country))
+		v.Check(certification != "", "certifications", "must not contain empty certifications")
+		v.Check(len(certification) <= 10, "certifications", "must not contain certifications longer than 10 bytes")
+	}
+
+	if movie.IMDbID != nil {
+		v.Check(validator.Mathces(*movie.IMDbID, IMDbIDRX), "imdb_id", "must be an IMDb title ID (tt0000000)")
+	}
+
+	if movie.TMDBID != nil {
+		v.Check(*movie.TMDBID > 0, "tmdb_id", "must be a positive integer")
+	}
 }
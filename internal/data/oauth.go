@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// OAuthState remembers an authorization request that was sent to an identity provider, until the
+// provider redirects the user back to the callback.
+type OAuthState struct {
+	Plaintext string
+	Provider  string
+	Verifier  string
+	Nonce     string
+	Expiry    time.Time
+}
+
+type OAuthStateModel struct {
+	DB *pgxpool.Pool
+}
+
+func (om *OAuthStateModel) Insert(state *OAuthState) error {
+	hash := sha256.Sum256([]byte(state.Plaintext))
+
+	query := `
+		INSERT INTO oauth_states(hash, provider, verifier, nonce, expiry)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	args := []any{hash[:], state.Provider, state.Verifier, state.Nonce, state.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := om.DB.Exec(ctx, query, args...)
+
+	return err
+}
+
+// Consume removes and returns the state, so that every authorization response can only be used once.
+func (om *OAuthStateModel) Consume(provider, plaintextState string) (*OAuthState, error) {
+	hash := sha256.Sum256([]byte(plaintextState))
+
+	query := `
+		DELETE FROM oauth_states
+		WHERE hash = $1 AND provider = $2 AND expiry > $3
+		RETURNING provider, verifier, nonce, expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	state := OAuthState{Plaintext: plaintextState}
+
+	err := om.DB.QueryRow(ctx, query, hash[:], provider, time.Now()).Scan(
+		&state.Provider,
+		&state.Verifier,
+		&state.Nonce,
+		&state.Expiry,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &state, nil
+}
+
+type IdentityModel struct {
+	DB *pgxpool.Pool
+}
+
+// GetUser returns the user linked to the subject of an identity provider.
+func (im *IdentityModel) GetUser(provider, subject string) (*User, error) {
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.locked, users.version
+		FROM users
+		INNER JOIN user_identities
+		ON users.id = user_identities.user_id
+		WHERE user_identities.provider = $1
+		AND user_identities.subject = $2`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := im.DB.QueryRow(ctx, query, provider, subject).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Locked,
+		&user.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (im *IdentityModel) Link(provider, subject string, userID int64) error {
+	query := `
+		INSERT INTO user_identities(provider, subject, user_id)
+		VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := im.DB.Exec(ctx, query, provider, subject, userID)
+
+	return err
+}
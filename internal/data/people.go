@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"time"
+)
+
+type Person struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"-"`
+	Name      string     `json:"name"`
+	BirthDate *time.Time `json:"birth_date,omitempty"`
+	Biography string     `json:"biography,omitempty"`
+	Version   int32      `json:"version"`
+}
+
+type PersonModel struct {
+	DB *pgxpool.Pool
+}
+
+func (pm *PersonModel) Insert(person *Person) error {
+	query := `
+		INSERT INTO people(name, birth_date, biography)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version`
+
+	args := []any{person.Name, person.BirthDate, person.Biography}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return pm.DB.QueryRow(ctx, query, args...).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (pm *PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, birth_date, biography, version
+		FROM people
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var person Person
+
+	err := pm.DB.QueryRow(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.BirthDate,
+		&person.Biography,
+		&person.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &person, nil
+}
+
+func (pm *PersonModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, name, birth_date, biography, version
+		FROM people
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := pm.DB.Query(ctx, query, name, filters.limit(), filters.offset())
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var totalRecords int
+
+	people, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Person, error) {
+		var person Person
+
+		err := row.Scan(&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.BirthDate,
+			&person.Biography,
+			&person.Version)
+
+		return &person, err
+	})
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(people) == 0 {
+		return []*Person{}, Metadata{}, nil
+	}
+
+	return people, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (pm *PersonModel) Update(person *Person) error {
+	query := `
+		UPDATE people
+		SET name = $1, birth_date = $2, biography = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+
+	args := []any{person.Name, person.BirthDate, person.Biography, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := pm.DB.QueryRow(ctx, query, args...).Scan(&person.Version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pm *PersonModel) Delete(id int64) error {
+	query := `
+		DELETE FROM people WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := pm.DB.Exec(ctx, query, id)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if person.BirthDate != nil {
+		v.Check(person.BirthDate.Year() >= 1800, "birth_date", "must be after 1800")
+		v.Check(person.BirthDate.Before(time.Now()), "birth_date", "must not be in the future")
+	}
+
+	v.Check(len(person.Biography) <= 10_000, "biography", "must not be more than 10000 bytes long")
+}
@@ -4,22 +4,51 @@ import (
 	"context"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"strings"
 	"time"
 )
 
 type Permissions []string
 
 type PermissionsModel struct {
-	DB *pgxpool.Pool
+	DB    *pgxpool.Pool
+	Cache *PermissionsCache
 }
 
 func (pm *PermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
+	if pm.Cache != nil {
+		if permissions, ok := pm.Cache.get(userID); ok {
+			return permissions, nil
+		}
+	}
+
+	permissions, err := pm.getAllForUser(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pm.Cache != nil {
+		pm.Cache.set(userID, permissions)
+	}
+
+	return permissions, nil
+}
+
+func (pm *PermissionsModel) getAllForUser(userID int64) (Permissions, error) {
 	query := `
 		SELECT permissions.code
-		FROM permissions 
-		INNER JOIN users_permissions ON users_permissions.permissions_id = permissions.id
-		INNER JOIN users ON users_permissions.user_id = users.id
-		WHERE users.id = $1`
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = $1
+		UNION
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+		INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+		WHERE users_roles.user_id = $1
+		ORDER BY code`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -42,6 +71,45 @@ func (pm *PermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
 		return nil, err
 	}
 
+	// return an empty array instead of null if there are no results
+	if len(permissions) == 0 {
+		return Permissions{}, nil
+	}
+
+	return permissions, nil
+}
+
+func (pm *PermissionsModel) GetAll() (Permissions, error) {
+	query := `
+		SELECT code
+		FROM permissions
+		ORDER BY code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := pm.DB.Query(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (string, error) {
+		var permission string
+
+		err := row.Scan(&permission)
+
+		return permission, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(permissions) == 0 {
+		return Permissions{}, nil
+	}
+
 	return permissions, nil
 }
 
@@ -50,22 +118,62 @@ func (pm *PermissionsModel) AddForUser(userID int64, codes ...string) error {
 		INSERT INTO users_permissions
 		SELECT $1, permissions.id 
 		FROM permissions 
-		WHERE permissions.code = ANY($2)`
+		WHERE permissions.code = ANY($2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := pm.DB.Exec(ctx, query, userID, codes)
+
+	if pm.Cache != nil {
+		pm.Cache.Invalidate(userID)
+	}
+
+	return err
+}
+
+func (pm *PermissionsModel) RemoveForUser(userID int64, codes ...string) error {
+	query := `
+		DELETE FROM users_permissions
+		USING permissions
+		WHERE users_permissions.permission_id = permissions.id
+		AND users_permissions.user_id = $1
+		AND permissions.code = ANY($2)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	_, err := pm.DB.Exec(ctx, query, userID, codes)
 
+	if pm.Cache != nil {
+		pm.Cache.Invalidate(userID)
+	}
+
 	return err
 }
 
+// Include reports whether the permissions grant code. A granted code ending in "*" is a wildcard that
+// covers every code with the same prefix, so "movies:*" grants both "movies:read" and "movies:write".
 func (p Permissions) Include(code string) bool {
 	for _, c := range p {
 		if c == code {
 			return true
 		}
+
+		if prefix, ok := strings.CutSuffix(c, "*"); ok && strings.HasPrefix(code, prefix) {
+			return true
+		}
 	}
 
 	return false
 }
+
+func ValidatePermissionCodes(v *validator.Validator, codes []string, known Permissions) {
+	v.Check(len(codes) >= 1, "codes", "must contain at least 1 permission code")
+	v.Check(validator.Unique(codes), "codes", "must not contain duplicate values")
+
+	for _, code := range codes {
+		v.Check(validator.PermittedValue(code, known...), "codes", "must only contain known permission codes")
+	}
+}
@@ -0,0 +1,154 @@
+package data
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"github.com/makarellav/cinego/internal/validator"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy holds the rules new passwords have to follow on top of ValidatePasswordPlaintext.
+type PasswordPolicy struct {
+	MinLength int
+	// MinCharClasses is how many of lowercase letters, uppercase letters, digits and symbols a
+	// password has to mix
+	MinCharClasses int
+	// MinEntropy is the minimum estimated strength in bits, 0 disables the check
+	MinEntropy float64
+	// BreachedDir is a directory of breached password hashes in the k-anonymity range format: one
+	// file per 5 character SHA-1 prefix, holding SUFFIX:COUNT lines. Empty disables the check.
+	BreachedDir string
+}
+
+// Validate checks plaintextPassword against the policy, adding any problems to v under the password
+// key. The user is used to reject passwords built from their own name or email address. An error is
+// only returned if the breached password list couldn't be read.
+func (p *PasswordPolicy) Validate(v *validator.Validator, plaintextPassword string, user *User) error {
+	ValidatePasswordPlaintext(v, plaintextPassword)
+
+	// v may already hold errors for other fields, which shouldn't stop the password from being checked
+	if _, failed := v.Errors["password"]; failed {
+		return nil
+	}
+
+	v.Check(len(plaintextPassword) >= p.MinLength, "password", "is too short")
+
+	classes, pool := passwordCharClasses(plaintextPassword)
+
+	v.Check(classes >= p.MinCharClasses, "password", "must mix lowercase and uppercase letters, digits or symbols")
+	v.Check(passwordEntropy(plaintextPassword, pool) >= p.MinEntropy, "password", "is too easy to guess")
+
+	lowered := strings.ToLower(plaintextPassword)
+
+	if user != nil {
+		local, _, _ := strings.Cut(strings.ToLower(user.Email), "@")
+
+		v.Check(len(local) < 3 || !strings.Contains(lowered, local), "password", "must not contain your email address")
+
+		for _, part := range strings.Fields(strings.ToLower(user.Name)) {
+			v.Check(len(part) < 3 || !strings.Contains(lowered, part), "password", "must not contain your name")
+		}
+	}
+
+	if _, failed := v.Errors["password"]; failed || p.BreachedDir == "" {
+		return nil
+	}
+
+	breached, err := p.isBreached(plaintextPassword)
+
+	if err != nil {
+		return err
+	}
+
+	v.Check(!breached, "password", "has appeared in a data breach, please choose a different one")
+
+	return nil
+}
+
+// isBreached looks the password up in the breached list. Only the file for the first five characters
+// of the hash is read, so the list can be the full multi-gigabyte set split into range files.
+func (p *PasswordPolicy) isBreached(plaintextPassword string) (bool, error) {
+	sum := sha1.Sum([]byte(plaintextPassword))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(p.BreachedDir, prefix))
+
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), ":")
+
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// passwordCharClasses returns how many character classes the password uses, along with the size of
+// the alphabet those classes make up.
+func passwordCharClasses(password string) (int, int) {
+	var lower, upper, digit, symbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes, pool := 0, 0
+
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}} {
+		if class.used {
+			classes++
+			pool += class.size
+		}
+	}
+
+	return classes, pool
+}
+
+// passwordEntropy is a rough estimate of the strength of a password in bits, based on the size of
+// its alphabet and the number of distinct characters in it.
+func passwordEntropy(password string, pool int) float64 {
+	if pool == 0 {
+		return 0
+	}
+
+	unique := make(map[rune]bool)
+
+	for _, r := range password {
+		unique[r] = true
+	}
+
+	return float64(len(unique)) * math.Log2(float64(pool))
+}
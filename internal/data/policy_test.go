@@ -0,0 +1,101 @@
+package data
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/makarellav/cinego/internal/validator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:      10,
+		MinCharClasses: 3,
+		MinEntropy:     40,
+	}
+
+	user := &User{Name: "Bob Marley", Email: "alice.smith@example.com"}
+
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{"valid", "correct-Horse7", ""},
+		{"empty", "", "must be provided"},
+		{"too long", strings.Repeat("aB3$", 19), "must not be more than 72 bytes long"},
+		{"too short", "Ab1!xyz9", "is too short"},
+		{"one character class", "alllowercaseletters", "must mix lowercase and uppercase letters, digits or symbols"},
+		{"low entropy", "Aa1Aa1Aa1Aa1", "is too easy to guess"},
+		{"contains email", "Alice.Smith#2024", "must not contain your email address"},
+		{"contains name", "Marley-Rules-99", "must not contain your name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+
+			err := policy.Validate(v, tt.password, user)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := v.Errors["password"]; got != tt.want {
+				t.Errorf("got error %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidateBreached(t *testing.T) {
+	const breached = "Summer-Sunset-1987"
+
+	sum := sha1.Sum([]byte(breached))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	dir := t.TempDir()
+	contents := "0000000000000000000000000000000000A:3\n" + strings.ToLower(hash[5:]) + ":42\n"
+
+	err := os.WriteFile(filepath.Join(dir, hash[:5]), []byte(contents), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8, BreachedDir: dir}
+
+	tests := []struct {
+		name        string
+		password    string
+		otherErrors bool
+		want        string
+	}{
+		{"breached", breached, false, "has appeared in a data breach, please choose a different one"},
+		{"breached with errors for other fields", breached, true, "has appeared in a data breach, please choose a different one"},
+		{"no range file", "Winter-Dawn-2001", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+
+			if tt.otherErrors {
+				v.AddKey("email", "must be a valid email address")
+			}
+
+			err := policy.Validate(v, tt.password, nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := v.Errors["password"]; got != tt.want {
+				t.Errorf("got error %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
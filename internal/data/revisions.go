@@ -0,0 +1,239 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"reflect"
+	"time"
+)
+
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+)
+
+// unrevisedMovieFields are computed or bookkeeping fields that aren't part of the edit history.
+var unrevisedMovieFields = []string{"id", "version", "average_rating", "review_count", "deleted_at"}
+
+// Change is the value of a field before and after a revision.
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type MovieRevision struct {
+	ID        int64             `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	MovieID   int64             `json:"movie_id"`
+	Version   int32             `json:"version"`
+	Action    string            `json:"action"`
+	UserID    *int64            `json:"user_id"`
+	Changes   map[string]Change `json:"changes"`
+	Snapshot  json.RawMessage   `json:"snapshot"`
+}
+
+// NewMovieRevision records the change of a movie from before to after. Before is nil for inserts;
+// for everything else after is the state that the movie was left in.
+func NewMovieRevision(action string, userID int64, before, after *Movie) (*MovieRevision, error) {
+	from, err := revisedFields(before)
+
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := revisedFields(after)
+
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+
+	for field, value := range to {
+		if !reflect.DeepEqual(from[field], value) {
+			changes[field] = Change{From: from[field], To: value}
+		}
+	}
+
+	for field, value := range from {
+		if _, ok := to[field]; !ok {
+			changes[field] = Change{From: value, To: nil}
+		}
+	}
+
+	snapshot, err := json.Marshal(after)
+
+	if err != nil {
+		return nil, err
+	}
+
+	revision := MovieRevision{
+		MovieID:  after.ID,
+		Version:  after.Version,
+		Action:   action,
+		Changes:  changes,
+		Snapshot: snapshot,
+	}
+
+	// anonymous users can't edit movies, but background jobs act without a user
+	if userID > 0 {
+		revision.UserID = &userID
+	}
+
+	return &revision, nil
+}
+
+// revisedFields returns the fields of movie as they appear in JSON, without the unrevised ones.
+func revisedFields(movie *Movie) (map[string]any, error) {
+	fields := make(map[string]any)
+
+	if movie == nil {
+		return fields, nil
+	}
+
+	js, err := json.Marshal(movie)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(js, &fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range unrevisedMovieFields {
+		delete(fields, field)
+	}
+
+	return fields, nil
+}
+
+// Movie returns the movie as it was after the revision.
+func (mr *MovieRevision) Movie() (*Movie, error) {
+	var movie Movie
+
+	err := json.Unmarshal(mr.Snapshot, &movie)
+
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", mr.ID, err)
+	}
+
+	return &movie, nil
+}
+
+// recordRevision adds the change of a movie from before to after to its history as part of tx, so
+// that the change and its revision are only ever written together.
+func recordRevision(ctx context.Context, tx pgx.Tx, action string, userID int64, before, after *Movie) error {
+	revision, err := NewMovieRevision(action, userID, before, after)
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO movie_revisions(movie_id, version, action, user_id, changes, snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	args := []any{revision.MovieID, revision.Version, revision.Action, revision.UserID, revision.Changes, revision.Snapshot}
+
+	_, err = tx.Exec(ctx, query, args...)
+
+	return err
+}
+
+type MovieRevisionModel struct {
+	DB *pgxpool.Pool
+}
+
+func (rm *MovieRevisionModel) Get(id int64, movieID int64) (*MovieRevision, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, movie_id, version, action, user_id, changes, snapshot
+		FROM movie_revisions
+		WHERE id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var revision MovieRevision
+
+	err := rm.DB.QueryRow(ctx, query, id, movieID).Scan(
+		&revision.ID,
+		&revision.CreatedAt,
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Action,
+		&revision.UserID,
+		&revision.Changes,
+		&revision.Snapshot,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+func (rm *MovieRevisionModel) GetAllForMovie(movieID int64, filters Filters) ([]*MovieRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, movie_id, version, action, user_id, changes, snapshot
+		FROM movie_revisions
+		WHERE movie_id = $1
+		ORDER BY %s %s, id DESC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := rm.DB.Query(ctx, query, movieID, filters.limit(), filters.offset())
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var totalRecords int
+
+	revisions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*MovieRevision, error) {
+		var revision MovieRevision
+
+		err := row.Scan(&totalRecords,
+			&revision.ID,
+			&revision.CreatedAt,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Action,
+			&revision.UserID,
+			&revision.Changes,
+			&revision.Snapshot)
+
+		return &revision, err
+	})
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(revisions) == 0 {
+		return []*MovieRevision{}, Metadata{}, nil
+	}
+
+	return revisions, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewMovieRevision(t *testing.T) {
+	imdbID := "tt0034583"
+	deletedAt := time.Now()
+
+	casablanca := Movie{
+		ID:      7,
+		Title:   "Casablanca",
+		Year:    1942,
+		Runtime: 102,
+		Genres:  []string{"drama", "romance"},
+		Version: 1,
+	}
+
+	retitled := casablanca
+	retitled.Title = "Casablanca (1942)"
+	retitled.Version = 2
+
+	regenred := casablanca
+	regenred.Genres = []string{"drama"}
+	regenred.IMDbID = &imdbID
+	regenred.Version = 2
+
+	cleared := casablanca
+	cleared.Year = 0
+	cleared.Version = 2
+
+	bookkeeping := casablanca
+	bookkeeping.AverageRating = 4.5
+	bookkeeping.ReviewCount = 12
+	bookkeeping.DeletedAt = &deletedAt
+	bookkeeping.Version = 2
+
+	tests := []struct {
+		name    string
+		userID  int64
+		before  *Movie
+		after   *Movie
+		changes map[string]Change
+	}{
+		{
+			name:   "insert",
+			userID: 3,
+			after:  &casablanca,
+			changes: map[string]Change{
+				"title":   {From: nil, To: "Casablanca"},
+				"year":    {From: nil, To: float64(1942)},
+				"runtime": {From: nil, To: "102 mins"},
+				"genres":  {From: nil, To: []any{"drama", "romance"}},
+			},
+		},
+		{
+			name:   "changed field",
+			userID: 3,
+			before: &casablanca,
+			after:  &retitled,
+			changes: map[string]Change{
+				"title": {From: "Casablanca", To: "Casablanca (1942)"},
+			},
+		},
+		{
+			name:   "changed slice and added field",
+			userID: 3,
+			before: &casablanca,
+			after:  &regenred,
+			changes: map[string]Change{
+				"genres":  {From: []any{"drama", "romance"}, To: []any{"drama"}},
+				"imdb_id": {From: nil, To: "tt0034583"},
+			},
+		},
+		{
+			name:   "removed field",
+			userID: 3,
+			before: &casablanca,
+			after:  &cleared,
+			changes: map[string]Change{
+				"year": {From: float64(1942), To: nil},
+			},
+		},
+		{
+			name:    "only unrevised fields",
+			before:  &casablanca,
+			after:   &bookkeeping,
+			changes: map[string]Change{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revision, err := NewMovieRevision(RevisionUpdate, tt.userID, tt.before, tt.after)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(revision.Changes, tt.changes) {
+				t.Errorf("got changes %#v, want %#v", revision.Changes, tt.changes)
+			}
+
+			if revision.MovieID != tt.after.ID || revision.Version != tt.after.Version {
+				t.Errorf("got movie %d version %d, want movie %d version %d", revision.MovieID, revision.Version, tt.after.ID, tt.after.Version)
+			}
+
+			switch {
+			case tt.userID == 0 && revision.UserID != nil:
+				t.Errorf("got user %d, want none", *revision.UserID)
+			case tt.userID != 0 && (revision.UserID == nil || *revision.UserID != tt.userID):
+				t.Errorf("got user %v, want %d", revision.UserID, tt.userID)
+			}
+
+			movie, err := revision.Movie()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if movie.Title != tt.after.Title || movie.Runtime != tt.after.Runtime || !reflect.DeepEqual(movie.Genres, tt.after.Genres) {
+				t.Errorf("snapshot gave %+v, want %+v", movie, tt.after)
+			}
+		})
+	}
+}
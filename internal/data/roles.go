@@ -0,0 +1,150 @@
+package data
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"time"
+)
+
+type Role struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions"`
+}
+
+type RoleModel struct {
+	DB    *pgxpool.Pool
+	Cache *PermissionsCache
+}
+
+func (rm *RoleModel) GetAll() ([]*Role, error) {
+	query := `
+		SELECT roles.id, roles.name, COALESCE(array_agg(permissions.code ORDER BY permissions.code)
+			FILTER (WHERE permissions.code IS NOT NULL), '{}')
+		FROM roles
+		LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+		LEFT JOIN permissions ON roles_permissions.permission_id = permissions.id
+		GROUP BY roles.id
+		ORDER BY roles.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := rm.DB.Query(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Role, error) {
+		var role Role
+
+		err := row.Scan(&role.ID, &role.Name, &role.Permissions)
+
+		return &role, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// return an empty array instead of null if there are no results
+	if len(roles) == 0 {
+		return []*Role{}, nil
+	}
+
+	return roles, nil
+}
+
+func (rm *RoleModel) GetAllForUser(userID int64) ([]string, error) {
+	query := `
+		SELECT roles.name
+		FROM roles
+		INNER JOIN users_roles ON users_roles.role_id = roles.id
+		WHERE users_roles.user_id = $1
+		ORDER BY roles.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := rm.DB.Query(ctx, query, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (string, error) {
+		var role string
+
+		err := row.Scan(&role)
+
+		return role, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		return []string{}, nil
+	}
+
+	return roles, nil
+}
+
+func (rm *RoleModel) AddForUser(userID int64, names ...string) error {
+	query := `
+		INSERT INTO users_roles
+		SELECT $1, roles.id
+		FROM roles
+		WHERE roles.name = ANY($2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := rm.DB.Exec(ctx, query, userID, names)
+
+	if rm.Cache != nil {
+		rm.Cache.Invalidate(userID)
+	}
+
+	return err
+}
+
+func (rm *RoleModel) RemoveForUser(userID int64, names ...string) error {
+	query := `
+		DELETE FROM users_roles
+		USING roles
+		WHERE users_roles.role_id = roles.id
+		AND users_roles.user_id = $1
+		AND roles.name = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := rm.DB.Exec(ctx, query, userID, names)
+
+	if rm.Cache != nil {
+		rm.Cache.Invalidate(userID)
+	}
+
+	return err
+}
+
+func ValidateRoleNames(v *validator.Validator, names []string, known []*Role) {
+	v.Check(len(names) >= 1, "roles", "must contain at least 1 role")
+	v.Check(validator.Unique(names), "roles", "must not contain duplicate values")
+
+	knownNames := make([]string, len(known))
+
+	for i, role := range known {
+		knownNames[i] = role.Name
+	}
+
+	for _, name := range names {
+		v.Check(validator.PermittedValue(name, knownNames...), "roles", "must only contain known roles")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"errors"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/makarellav/cinego/internal/validator"
 	"time"
@@ -13,14 +15,22 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	ScopePasswordReset  = "password-reset"
+	ScopeRefresh        = "refresh"
+	ScopeTwoFactor      = "2fa-challenge"
 )
 
+var ErrTokenReused = errors.New("token reused")
+
 type Token struct {
-	Plaintext string    `json:"token"`
-	Hash      []byte    `json:"-"`
-	UserID    int64     `json:"-"`
-	Expiry    time.Time `json:"expiry"`
-	Scope     string    `json:"-"`
+	Plaintext  string     `json:"token,omitempty"`
+	Hash       []byte     `json:"-"`
+	UserID     int64      `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Expiry     time.Time  `json:"expiry"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Scope      string     `json:"-"`
+	Family     []byte     `json:"-"`
 }
 
 type TokenModel struct {
@@ -62,21 +72,214 @@ func (tm *TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token
 	return token, err
 }
 
+// NewFamily returns an identifier for a new token family. Every token issued for the same login
+// shares a family, so that they can all be revoked together.
+func (tm *TokenModel) NewFamily() ([]byte, error) {
+	family := make([]byte, 16)
+
+	_, err := rand.Read(family)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return family, nil
+}
+
+func (tm *TokenModel) NewInFamily(userID int64, ttl time.Duration, scope string, family []byte) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token.Family = family
+
+	err = tm.Insert(token)
+
+	return token, err
+}
+
 func (tm *TokenModel) Insert(token *Token) error {
 	query := `
-		INSERT INTO tokens(hash, user_id, expiry, scope)
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO tokens(hash, user_id, expiry, scope, family)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope, token.Family}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return tm.DB.QueryRow(ctx, query, args...).Scan(&token.CreatedAt)
+}
+
+func (tm *TokenModel) GetAllForUser(scope string, userID int64) ([]*Token, error) {
+	query := `
+		SELECT hash, user_id, created_at, expiry, last_used_at, scope, family
+		FROM tokens
+		WHERE scope = $1 AND user_id = $2 AND expiry > $3
+		ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := tm.DB.Query(ctx, query, scope, userID, time.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Token, error) {
+		var token Token
+
+		err := row.Scan(
+			&token.Hash,
+			&token.UserID,
+			&token.CreatedAt,
+			&token.Expiry,
+			&token.LastUsedAt,
+			&token.Scope,
+			&token.Family,
+		)
 
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+		return &token, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// return an empty array instead of null if there are no results
+	if len(tokens) == 0 {
+		return []*Token{}, nil
+	}
+
+	return tokens, nil
+}
+
+// Touch records that the token was just used. The timestamp is only refreshed once a minute so that
+// a burst of authenticated requests doesn't turn into a burst of writes.
+func (tm *TokenModel) Touch(scope string, plaintextToken string) error {
+	hash := sha256.Sum256([]byte(plaintextToken))
+
+	query := `
+		UPDATE tokens
+		SET last_used_at = NOW()
+		WHERE hash = $1 AND scope = $2
+		AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := tm.DB.Exec(ctx, query, args...)
+	_, err := tm.DB.Exec(ctx, query, hash[:], scope)
 
 	return err
 }
 
+func (tm *TokenModel) Delete(scope string, plaintextToken string) error {
+	hash := sha256.Sum256([]byte(plaintextToken))
+
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1 AND scope = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := tm.DB.Exec(ctx, query, hash[:], scope)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteFamily removes the token of the user along with every other token issued in the same family.
+func (tm *TokenModel) DeleteFamily(scope string, plaintextToken string, userID int64) error {
+	hash := sha256.Sum256([]byte(plaintextToken))
+
+	query := `
+		DELETE FROM tokens
+		WHERE (hash = $1 AND scope = $2 AND user_id = $3)
+		OR family = (SELECT family FROM tokens WHERE hash = $1 AND scope = $2 AND user_id = $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := tm.DB.Exec(ctx, query, hash[:], scope, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// UseRefresh marks a refresh token as used and returns it. A refresh token can only be used once:
+// if one comes back after it has already been used, it has most likely been stolen, so the whole
+// family is revoked and ErrTokenReused is returned.
+func (tm *TokenModel) UseRefresh(plaintextToken string) (*Token, error) {
+	hash := sha256.Sum256([]byte(plaintextToken))
+
+	query := `
+		UPDATE tokens
+		SET used_at = NOW()
+		WHERE hash = $1 AND scope = $2 AND used_at IS NULL AND expiry > $3
+		RETURNING user_id, created_at, expiry, family`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token := Token{
+		Hash:  hash[:],
+		Scope: ScopeRefresh,
+	}
+
+	err := tm.DB.QueryRow(ctx, query, hash[:], ScopeRefresh, time.Now()).Scan(
+		&token.UserID,
+		&token.CreatedAt,
+		&token.Expiry,
+		&token.Family,
+	)
+
+	if err == nil {
+		return &token, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	query = `
+		DELETE FROM tokens
+		WHERE family = (
+			SELECT family FROM tokens
+			WHERE hash = $1 AND scope = $2 AND used_at IS NOT NULL
+		)`
+
+	result, err := tm.DB.Exec(ctx, query, hash[:], ScopeRefresh)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if result.RowsAffected() == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return nil, ErrTokenReused
+}
+
 func (tm *TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	query := `
 		DELETE FROM tokens 
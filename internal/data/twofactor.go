@@ -0,0 +1,219 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/makarellav/cinego/internal/validator"
+	"strings"
+	"time"
+)
+
+var ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+
+type TwoFactor struct {
+	UserID    int64
+	CreatedAt time.Time
+	Secret    []byte
+	Enabled   bool
+	LastStep  int64
+}
+
+type TwoFactorModel struct {
+	DB *pgxpool.Pool
+}
+
+func (tm *TwoFactorModel) Get(userID int64) (*TwoFactor, error) {
+	query := `
+		SELECT user_id, created_at, secret, enabled, last_step
+		FROM users_totp
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var tf TwoFactor
+
+	err := tm.DB.QueryRow(ctx, query, userID).Scan(
+		&tf.UserID,
+		&tf.CreatedAt,
+		&tf.Secret,
+		&tf.Enabled,
+		&tf.LastStep,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tf, nil
+}
+
+// Start stores a new secret for a user who is about to enrol. An unconfirmed enrolment is simply
+// replaced, but ErrTwoFactorEnabled is returned if the user has already confirmed one.
+func (tm *TwoFactorModel) Start(userID int64, secret []byte) error {
+	query := `
+		INSERT INTO users_totp(user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = NOW(), last_step = 0
+		WHERE users_totp.enabled = false`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := tm.DB.Exec(ctx, query, userID, secret)
+
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// Enable confirms the enrolment and replaces any previous recovery codes with codes.
+func (tm *TwoFactorModel) Enable(userID int64, codes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := tm.DB.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE users_totp SET enabled = true WHERE user_id = $1`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err = tx.Exec(ctx, `INSERT INTO recovery_codes(hash, user_id) VALUES ($1, $2)`, hashRecoveryCode(code), userID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseStep records the time step of a code that was just accepted. It returns false if a code from
+// the same or a later step was already used, so that an intercepted code can't be replayed.
+func (tm *TwoFactorModel) UseStep(userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE users_totp
+		SET last_step = $2
+		WHERE user_id = $1 AND last_step < $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := tm.DB.Exec(ctx, query, userID, step)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode consumes a recovery code, returning false if it doesn't belong to the user.
+func (tm *TwoFactorModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	query := `
+		DELETE FROM recovery_codes
+		WHERE hash = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := tm.DB.Exec(ctx, query, hashRecoveryCode(code), userID)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (tm *TwoFactorModel) Delete(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := tm.DB.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM users_totp WHERE user_id = $1`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GenerateRecoveryCodes returns n random single-use codes formatted as two groups of five characters.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	for i := range codes {
+		randomBytes := make([]byte, 10)
+
+		_, err := rand.Read(randomBytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(randomBytes))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) []byte {
+	normalised := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	hash := sha256.Sum256([]byte(normalised))
+
+	return hash[:]
+}
+
+func ValidateTwoFactorCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) <= 16, "code", "must not be more than 16 bytes long")
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/makarellav/cinego/internal/validator"
-	"golang.org/x/crypto/bcrypt"
 	"strings"
 	"time"
 )
@@ -26,6 +26,7 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
+	Locked    bool      `json:"locked"`
 	Version   int       `json:"-"`
 }
 
@@ -36,6 +37,8 @@ type password struct {
 
 type UserModel struct {
 	DB *pgxpool.Pool
+	// Hashing are the parameters for new password hashes
+	Hashing HashParams
 }
 
 func (u *User) IsAnonymous() bool {
@@ -44,11 +47,11 @@ func (u *User) IsAnonymous() bool {
 
 func (um *UserModel) Insert(user *User) error {
 	query := `
-		INSERT INTO users(name, email, password_hash, activated)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users(name, email, password_hash, activated, locked)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, version`
 
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.Locked}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -67,9 +70,47 @@ func (um *UserModel) Insert(user *User) error {
 	return nil
 }
 
+func (um *UserModel) Get(id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, locked, version
+		FROM users
+		WHERE id = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := um.DB.QueryRow(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Locked,
+		&user.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
 func (um *UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, activated, version
+		SELECT id, created_at, name, email, password_hash, activated, locked, version
 		FROM users
 		WHERE email = $1
 	`
@@ -86,6 +127,7 @@ func (um *UserModel) GetByEmail(email string) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Locked,
 		&user.Version,
 	)
 
@@ -101,11 +143,64 @@ func (um *UserModel) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
+// GetAll returns a page of users. Empty strings and nil values leave the corresponding filter out.
+func (um *UserModel) GetAll(name, email string, activated *bool, createdFrom, createdTo *time.Time, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, name, email, activated, locked, version
+		FROM users
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (strpos(email, $2) > 0 OR $2 = '')
+		AND ($3::boolean IS NULL OR activated = $3)
+		AND ($4::timestamptz IS NULL OR created_at >= $4)
+		AND ($5::timestamptz IS NULL OR created_at < $5)
+		ORDER BY %s %s, id ASC
+		LIMIT $6 OFFSET $7`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []any{name, email, activated, createdFrom, createdTo, filters.limit(), filters.offset()}
+
+	rows, err := um.DB.Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var totalRecords int
+
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*User, error) {
+		var user User
+
+		err := row.Scan(&totalRecords,
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Activated,
+			&user.Locked,
+			&user.Version)
+
+		return &user, err
+	})
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// return an empty array instead of null if there are no results
+	if len(users) == 0 {
+		return []*User{}, Metadata{}, nil
+	}
+
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
 func (um *UserModel) GetByToken(tokenScope string, token string) (*User, error) {
 	hash := sha256.Sum256([]byte(token))
 
 	query := `
-		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.locked, users.version
 		FROM users
 		INNER JOIN tokens 
 		ON users.id = tokens.user_id
@@ -127,6 +222,7 @@ func (um *UserModel) GetByToken(tokenScope string, token string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Locked,
 		&user.Version,
 	)
 
@@ -145,11 +241,11 @@ func (um *UserModel) GetByToken(tokenScope string, token string) (*User, error)
 func (um *UserModel) Update(user *User) error {
 	query := `
 		UPDATE users 
-		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		SET name = $1, email = $2, password_hash = $3, activated = $4, locked = $5, version = version + 1
+		WHERE id = $6 AND version = $7
 		RETURNING version`
 
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.ID, user.Version}
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.Locked, user.ID, user.Version}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -170,32 +266,47 @@ func (um *UserModel) Update(user *User) error {
 	return nil
 }
 
-func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), bcrypt.DefaultCost)
+func (um *UserModel) Delete(id int64) error {
+	query := `
+		DELETE FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := um.DB.Exec(ctx, query, id)
 
 	if err != nil {
 		return err
 	}
 
-	p.hash = hash
-	p.plaintext = &plaintextPassword
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
 
 	return nil
 }
 
-func (p *password) Matches(plaintextPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+func (p *password) Set(plaintextPassword string, params HashParams) error {
+	hash, err := params.hash(plaintextPassword)
 
 	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return false, nil
-		default:
-			return false, err
-		}
+		return err
 	}
 
-	return true, nil
+	p.hash = hash
+	p.plaintext = &plaintextPassword
+
+	return nil
+}
+
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	return compareHash(p.hash, plaintextPassword)
+}
+
+// NeedsRehash reports whether the hash was created with other parameters than params, in which case
+// it should be replaced the next time the plaintext password is known.
+func (p *password) NeedsRehash(params HashParams) bool {
+	return params.outdated(p.hash)
 }
 
 func ValidateEmail(v *validator.Validator, email string) {
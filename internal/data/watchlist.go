@@ -0,0 +1,220 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"time"
+)
+
+var ErrAlreadyInWatchlist = errors.New("movie already in watchlist")
+
+type WatchlistEntry struct {
+	Position int32     `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie"`
+}
+
+type WatchlistModel struct {
+	DB *pgxpool.Pool
+}
+
+// Add puts the movie at the end of the user's watchlist.
+func (wm *WatchlistModel) Add(userID, movieID int64) (*WatchlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := wm.DB.Begin(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// the same lock as for moves, otherwise concurrent adds would end up at the same position
+	_, err = tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO watchlist(user_id, movie_id, position)
+		SELECT $1, $2, COALESCE(MAX(position), 0) + 1
+		FROM watchlist
+		WHERE user_id = $1
+		RETURNING position, added_at`
+
+	var entry WatchlistEntry
+
+	err = tx.QueryRow(ctx, query, userID, movieID).Scan(&entry.Position, &entry.AddedAt)
+
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), DuplicateKeyCode):
+			return nil, ErrAlreadyInWatchlist
+		default:
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (wm *WatchlistModel) GetAll(userID int64, filters Filters) ([]*WatchlistEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), watchlist.position, watchlist.added_at, %s
+		FROM watchlist
+		INNER JOIN movies ON movies.id = watchlist.movie_id %s
+		WHERE watchlist.user_id = $1 AND movies.deleted_at IS NULL
+		ORDER BY %s %s, movies.id ASC
+		LIMIT $2 OFFSET $3`, movieColumns, movieRatingsJoin, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := wm.DB.Query(ctx, query, userID, filters.limit(), filters.offset())
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var totalRecords int
+
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*WatchlistEntry, error) {
+		entry := WatchlistEntry{Movie: &Movie{}}
+
+		err := row.Scan(append([]any{&totalRecords, &entry.Position, &entry.AddedAt}, movieFields(entry.Movie)...)...)
+
+		return &entry, err
+	})
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(entries) == 0 {
+		return []*WatchlistEntry{}, Metadata{}, nil
+	}
+
+	return entries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Move puts the movie at position in the user's watchlist, shifting the movies in between by one.
+// Positions past the end of the list move the movie to the end.
+func (wm *WatchlistModel) Move(userID, movieID int64, position int32) (int32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := wm.DB.Begin(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// lock the user so that concurrent moves can't leave gaps or duplicate positions behind
+	_, err = tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var current, count int32
+
+	err = tx.QueryRow(ctx, `
+		SELECT position, (SELECT COUNT(*) FROM watchlist WHERE user_id = $1)
+		FROM watchlist
+		WHERE user_id = $1 AND movie_id = $2`, userID, movieID).Scan(&current, &count)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	position = min(position, count)
+
+	switch {
+	case position < current:
+		_, err = tx.Exec(ctx, `
+			UPDATE watchlist SET position = position + 1
+			WHERE user_id = $1 AND position >= $2 AND position < $3`, userID, position, current)
+	case position > current:
+		_, err = tx.Exec(ctx, `
+			UPDATE watchlist SET position = position - 1
+			WHERE user_id = $1 AND position > $2 AND position <= $3`, userID, current, position)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE watchlist SET position = $3 WHERE user_id = $1 AND movie_id = $2`, userID, movieID, position)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return position, tx.Commit(ctx)
+}
+
+// Remove takes the movie off the user's watchlist and closes the gap it leaves behind.
+func (wm *WatchlistModel) Remove(userID, movieID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := wm.DB.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	var position int32
+
+	err = tx.QueryRow(ctx, `
+		DELETE FROM watchlist
+		WHERE user_id = $1 AND movie_id = $2
+		RETURNING position`, userID, movieID).Scan(&position)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE watchlist SET position = position - 1
+		WHERE user_id = $1 AND position > $2`, userID, position)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
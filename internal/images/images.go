@@ -0,0 +1,190 @@
+package images
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"slices"
+)
+
+var ErrUnsupportedFormat = errors.New("images: unsupported image format")
+
+// extensions are the file extensions of the supported content types.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Inspect sniffs the content type of data and reads the dimensions of the image without decoding
+// the pixels, so that oversized images can be rejected cheaply.
+func Inspect(data []byte) (string, image.Config, error) {
+	contentType := http.DetectContentType(data)
+
+	var cfg image.Config
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		cfg, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case "image/png":
+		cfg, err = png.DecodeConfig(bytes.NewReader(data))
+	default:
+		return contentType, image.Config{}, ErrUnsupportedFormat
+	}
+
+	return contentType, cfg, err
+}
+
+// Extension returns the file extension for a supported content type.
+func Extension(contentType string) string {
+	return extensions[contentType]
+}
+
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+
+	return img, err
+}
+
+// Thumbnails scales img down to each of widths, keeping the aspect ratio, and encodes them as
+// JPEGs in the same order. Images that are already narrower are not scaled up. Transparent areas
+// are filled with white.
+func Thumbnails(img image.Image, widths []int) ([][]byte, error) {
+	b := img.Bounds()
+
+	// flatten onto an opaque RGBA canvas once, which also gives resize direct access to the pixels
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Over)
+
+	// every thumbnail is scaled from the next larger one, so that the full size canvas can be
+	// dropped after the first
+	order := make([]int, len(widths))
+
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortFunc(order, func(i, j int) int {
+		return cmp.Compare(widths[j], widths[i])
+	})
+
+	thumbnails := make([][]byte, len(widths))
+
+	for _, i := range order {
+		width := widths[i]
+
+		if width < src.Rect.Dx() {
+			height := max(1, int(math.Round(float64(b.Dy())*float64(width)/float64(b.Dx()))))
+			src = resize(src, width, height)
+		}
+
+		var buf bytes.Buffer
+
+		err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 85})
+
+		if err != nil {
+			return nil, err
+		}
+
+		thumbnails[i] = buf.Bytes()
+	}
+
+	return thumbnails, nil
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// weights returns for every destination pixel the source pixels it covers and how much of each,
+// which is an area averaging (box) filter suited for scaling down.
+func weights(srcSize, dstSize int) [][]contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	result := make([][]contribution, dstSize)
+
+	for i := range result {
+		start := float64(i) * scale
+		end := start + scale
+
+		for j := int(start); j < srcSize && float64(j) < end; j++ {
+			overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+
+			if overlap > 0 {
+				result[i] = append(result[i], contribution{index: j, weight: overlap / scale})
+			}
+		}
+	}
+
+	return result
+}
+
+// resize scales src to width x height. Every source row is scaled horizontally on its own and added
+// to the destination rows it covers, so that only a destination sized buffer is needed.
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	srcW, srcH := src.Rect.Dx(), src.Rect.Dy()
+
+	horizontal := weights(srcW, width)
+
+	// the destination rows every source row contributes to
+	targets := make([][]contribution, srcH)
+
+	for y, contributions := range weights(srcH, height) {
+		for _, c := range contributions {
+			targets[c.index] = append(targets[c.index], contribution{index: y, weight: c.weight})
+		}
+	}
+
+	acc := make([]float32, width*height*4)
+	row := make([]float32, width*4)
+
+	for y := 0; y < srcH; y++ {
+		pix := src.Pix[y*src.Stride:]
+
+		for x, contributions := range horizontal {
+			var r, g, b, a float32
+
+			for _, c := range contributions {
+				p := pix[c.index*4:]
+				w := float32(c.weight)
+				r += float32(p[0]) * w
+				g += float32(p[1]) * w
+				b += float32(p[2]) * w
+				a += float32(p[3]) * w
+			}
+
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = r, g, b, a
+		}
+
+		for _, t := range targets[y] {
+			out := acc[t.index*width*4:]
+			w := float32(t.weight)
+
+			for i, v := range row {
+				out[i] += v * w
+			}
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for i, v := range acc[y*width*4 : (y+1)*width*4] {
+			dst.Pix[y*dst.Stride+i] = clamp(v)
+		}
+	}
+
+	return dst
+}
+
+func clamp(v float32) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(float64(v)))))
+}
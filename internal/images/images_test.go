@@ -0,0 +1,94 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestThumbnails(t *testing.T) {
+	// the left half is opaque red and the right half fully transparent
+	src := image.NewNRGBA(image.Rect(10, 20, 410, 220))
+
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		for x := src.Rect.Min.X; x < src.Rect.Min.X+200; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	widths := []int{100, 1000, 3}
+
+	thumbnails, err := Thumbnails(src, widths)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		width, height int
+	}{
+		{100, 50},
+		// never scaled up
+		{400, 200},
+		{3, 2},
+	}
+
+	for i, tt := range tests {
+		img, err := jpeg.Decode(bytes.NewReader(thumbnails[i]))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := img.Bounds()
+
+		if b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("width %d: got %dx%d, want %dx%d", widths[i], b.Dx(), b.Dy(), tt.width, tt.height)
+		}
+
+		if b.Dx() < 10 {
+			continue
+		}
+
+		left := color.RGBAModel.Convert(img.At(b.Dx()/4, b.Dy()/2)).(color.RGBA)
+		right := color.RGBAModel.Convert(img.At(3*b.Dx()/4, b.Dy()/2)).(color.RGBA)
+
+		if !near(left, color.RGBA{R: 255, A: 255}) || !near(right, color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+			t.Errorf("width %d: got %v on the left and %v on the right, want red and white", widths[i], left, right)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// a 4x1 gradient averaged down to 2x1
+	src := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	copy(src.Pix, []uint8{
+		0, 0, 0, 255,
+		100, 100, 100, 255,
+		200, 200, 200, 255,
+		255, 255, 255, 255,
+	})
+
+	dst := resize(src, 2, 1)
+
+	want := []uint8{50, 50, 50, 255, 228, 228, 228, 255}
+
+	if !bytes.Equal(dst.Pix, want) {
+		t.Errorf("got %v, want %v", dst.Pix, want)
+	}
+}
+
+// near allows for the loss of JPEG compression.
+func near(got, want color.RGBA) bool {
+	diff := func(a, b uint8) int {
+		if a > b {
+			return int(a - b)
+		}
+
+		return int(b - a)
+	}
+
+	return diff(got.R, want.R) < 16 && diff(got.G, want.G) < 16 && diff(got.B, want.B) < 16
+}
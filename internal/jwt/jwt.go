@@ -0,0 +1,236 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// leeway is the clock skew tolerated when checking the time based claims
+const leeway = 30 * time.Second
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+var encoding = base64.RawURLEncoding
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+type Claims struct {
+	Issuer      string   `json:"iss,omitempty"`
+	Subject     string   `json:"sub"`
+	IssuedAt    int64    `json:"iat"`
+	NotBefore   int64    `json:"nbf"`
+	ExpiresAt   int64    `json:"exp"`
+	Activated   bool     `json:"activated"`
+	Permissions []string `json:"permissions"`
+}
+
+type Key struct {
+	ID         string
+	Algorithm  string
+	secret     []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// NewKey creates a key for the given algorithm. For HS256 the material is the shared secret, which
+// must be at least 32 bytes long; for EdDSA it is a 32 byte Ed25519 seed.
+func NewKey(id, algorithm string, material []byte) (*Key, error) {
+	if id == "" {
+		return nil, errors.New("jwt: key id must not be empty")
+	}
+
+	key := Key{ID: id, Algorithm: algorithm}
+
+	switch algorithm {
+	case AlgHS256:
+		if len(material) < 32 {
+			return nil, fmt.Errorf("jwt: HS256 key %q must be at least 32 bytes long", id)
+		}
+
+		key.secret = material
+	case AlgEdDSA:
+		if len(material) != ed25519.SeedSize {
+			return nil, fmt.Errorf("jwt: EdDSA key %q must be a %d byte seed", id, ed25519.SeedSize)
+		}
+
+		key.privateKey = ed25519.NewKeyFromSeed(material)
+		key.publicKey = key.privateKey.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", algorithm)
+	}
+
+	return &key, nil
+}
+
+func (k *Key) sign(signingInput []byte) []byte {
+	switch k.Algorithm {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(signingInput)
+
+		return mac.Sum(nil)
+	default:
+		return ed25519.Sign(k.privateKey, signingInput)
+	}
+}
+
+func (k *Key) verify(signingInput, signature []byte) bool {
+	switch k.Algorithm {
+	case AlgHS256:
+		return hmac.Equal(k.sign(signingInput), signature)
+	default:
+		return ed25519.Verify(k.publicKey, signingInput, signature)
+	}
+}
+
+// KeySet signs new tokens with a single active key and verifies tokens signed with any key it knows
+// about, which lets old keys be kept around for verification while they are rotated out.
+type KeySet struct {
+	issuer     string
+	signingKey *Key
+	keys       map[string]*Key
+}
+
+func NewKeySet(issuer, signingKeyID string, keys ...*Key) (*KeySet, error) {
+	ks := KeySet{
+		issuer: issuer,
+		keys:   make(map[string]*Key, len(keys)),
+	}
+
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("jwt: duplicate key id %q", key.ID)
+		}
+
+		ks.keys[key.ID] = key
+	}
+
+	signingKey, ok := ks.keys[signingKeyID]
+
+	if !ok {
+		return nil, fmt.Errorf("jwt: signing key %q is not in the key set", signingKeyID)
+	}
+
+	ks.signingKey = signingKey
+
+	return &ks, nil
+}
+
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	claims.Issuer = ks.issuer
+
+	h, err := json.Marshal(header{Algorithm: ks.signingKey.Algorithm, Type: "JWT", KeyID: ks.signingKey.ID})
+
+	if err != nil {
+		return "", err
+	}
+
+	c, err := json.Marshal(claims)
+
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+	signature := ks.signingKey.sign([]byte(signingInput))
+
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+func (ks *KeySet) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	rawHeader, err := encoding.DecodeString(parts[0])
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+
+	err = json.Unmarshal(rawHeader, &h)
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := ks.keys[h.KeyID]
+
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	// never let the token pick the algorithm, otherwise a token could downgrade the verification
+	if h.Algorithm != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	rawClaims, err := encoding.DecodeString(parts[1])
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+
+	err = json.Unmarshal(rawClaims, &claims)
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Issuer != ks.issuer {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+
+	if now.Add(leeway).Unix() < claims.NotBefore {
+		return nil, ErrInvalidToken
+	}
+
+	if now.Add(-leeway).Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// IsJWT reports whether token has the shape of a compact serialised JWT.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -0,0 +1,235 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T, id, algorithm string) *Key {
+	t.Helper()
+
+	material := bytes.Repeat([]byte(id[:1]), 32)
+
+	key, err := NewKey(id, algorithm, material)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func validClaims() Claims {
+	now := time.Now()
+
+	return Claims{
+		Subject:     "42",
+		IssuedAt:    now.Unix(),
+		NotBefore:   now.Unix(),
+		ExpiresAt:   now.Add(15 * time.Minute).Unix(),
+		Activated:   true,
+		Permissions: []string{"movies:read"},
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		algorithm string
+		material  []byte
+		valid     bool
+	}{
+		{"HS256", "k1", AlgHS256, make([]byte, 32), true},
+		{"HS256 short secret", "k1", AlgHS256, make([]byte, 31), false},
+		{"EdDSA", "k1", AlgEdDSA, make([]byte, 32), true},
+		{"EdDSA wrong seed size", "k1", AlgEdDSA, make([]byte, 64), false},
+		{"unsupported algorithm", "k1", "none", make([]byte, 32), false},
+		{"empty id", "", AlgHS256, make([]byte, 32), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKey(tt.id, tt.algorithm, tt.material)
+
+			if (err == nil) != tt.valid {
+				t.Errorf("NewKey() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{AlgHS256, AlgEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			ks, err := NewKeySet("cinego", "a", newTestKey(t, "a", algorithm))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := ks.Sign(validClaims())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !IsJWT(token) {
+				t.Fatalf("%q doesn't look like a JWT", token)
+			}
+
+			claims, err := ks.Verify(token)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims.Subject != "42" || claims.Issuer != "cinego" || !claims.Activated || len(claims.Permissions) != 1 {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := newTestKey(t, "old", AlgHS256)
+	current := newTestKey(t, "new", AlgEdDSA)
+
+	before, err := NewKeySet("cinego", "old", old)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := before.Sign(validClaims())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewKeySet("cinego", "new", current, old)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := after.Verify(token); err != nil {
+		t.Errorf("token signed with a retired key was rejected: %v", err)
+	}
+
+	retired, err := NewKeySet("cinego", "new", current)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := retired.Verify(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got error %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	key := newTestKey(t, "a", AlgHS256)
+
+	ks, err := NewKeySet("cinego", "a", key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewKeySet("someone-else", "a", key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(ks *KeySet, modify func(c *Claims)) string {
+		claims := validClaims()
+
+		if modify != nil {
+			modify(&claims)
+		}
+
+		token, err := ks.Sign(claims)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return token
+	}
+
+	valid := sign(ks, nil)
+	parts := strings.Split(valid, ".")
+
+	// a token that claims to be unsigned, which must never be accepted
+	noneHeader, _ := json.Marshal(header{Algorithm: "none", Type: "JWT", KeyID: "a"})
+	unsigned := encoding.EncodeToString(noneHeader) + "." + parts[1] + "."
+
+	tamperedClaims := validClaims()
+	tamperedClaims.Issuer = "cinego"
+	tamperedClaims.Permissions = []string{"users:admin"}
+	rawTampered, _ := json.Marshal(tamperedClaims)
+	tampered := parts[0] + "." + encoding.EncodeToString(rawTampered) + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"malformed", "not-a-token", ErrInvalidToken},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".!!!", ErrInvalidToken},
+		{"tampered claims", tampered, ErrInvalidToken},
+		{"algorithm none", unsigned, ErrInvalidToken},
+		{"other issuer", sign(other, nil), ErrInvalidToken},
+		{"expired", sign(ks, func(c *Claims) { c.ExpiresAt = time.Now().Add(-time.Minute).Unix() }), ErrExpiredToken},
+		{"not yet valid", sign(ks, func(c *Claims) { c.NotBefore = time.Now().Add(time.Hour).Unix() }), ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.Verify(tt.token)
+
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithinLeeway(t *testing.T) {
+	ks, err := NewKeySet("cinego", "a", newTestKey(t, "a", AlgHS256))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := validClaims()
+	claims.ExpiresAt = time.Now().Add(-leeway / 2).Unix()
+
+	token, err := ks.Sign(claims)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.Verify(token); err != nil {
+		t.Errorf("token expired within the leeway was rejected: %v", err)
+	}
+}
+
+func TestNewKeySet(t *testing.T) {
+	a := newTestKey(t, "a", AlgHS256)
+
+	if _, err := NewKeySet("cinego", "missing", a); err == nil {
+		t.Error("key set without its signing key was accepted")
+	}
+
+	if _, err := NewKeySet("cinego", "a", a, newTestKey(t, "a", AlgEdDSA)); err == nil {
+		t.Error("key set with duplicate key ids was accepted")
+	}
+}
@@ -0,0 +1,471 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leeway is the clock skew tolerated when checking the time based claims of an ID token
+const leeway = time.Minute
+
+// minRefreshInterval stops a stream of tokens with unknown key IDs from hammering the JWKS endpoint
+const minRefreshInterval = time.Minute
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrExchangeFailed = errors.New("authorization code exchange failed")
+)
+
+var encoding = base64.RawURLEncoding
+
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims are the claims of a verified ID token that are needed to sign a user in.
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified *bool    `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// HasVerifiedEmail reports whether the provider vouches for the email address. Providers that leave
+// email_verified out don't.
+func (c *Claims) HasVerifiedEmail() bool {
+	return c.Email != "" && c.EmailVerified != nil && *c.EmailVerified
+}
+
+// audience is either a single string or an array of strings in an ID token
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+
+		return nil
+	}
+
+	var multiple []string
+
+	err := json.Unmarshal(data, &multiple)
+
+	if err != nil {
+		return err
+	}
+
+	*a = multiple
+
+	return nil
+}
+
+// Provider talks to a single OpenID Connect identity provider. Its endpoints are discovered lazily
+// on first use, so that the API can start while the provider is unreachable.
+type Provider struct {
+	Config
+
+	client *http.Client
+
+	mu                    sync.Mutex
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+	keys                  map[string]crypto.PublicKey
+	keysFetchedAt         time.Time
+}
+
+func New(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		Config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GenerateVerifier returns a random PKCE code verifier, which is also suitable as a state or nonce.
+func GenerateVerifier() (string, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(randomBytes), nil
+}
+
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tokenEndpoint != "" {
+		return nil
+	}
+
+	var document struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &document)
+
+	if err != nil {
+		return fmt.Errorf("oidc: discovery for %s: %w", p.Name, err)
+	}
+
+	if document.Issuer != p.Issuer {
+		return fmt.Errorf("oidc: discovery for %s returned issuer %q, expected %q", p.Name, document.Issuer, p.Issuer)
+	}
+
+	p.authorizationEndpoint = document.AuthorizationEndpoint
+	p.tokenEndpoint = document.TokenEndpoint
+	p.jwksURI = document.JWKSURI
+
+	return nil
+}
+
+// AuthCodeURL returns the URL of the provider's login page for an authorization code flow with PKCE.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	err := p.discover(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", encoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return p.authorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for the raw ID token issued with it.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	err := p.discover(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.client.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1_048_576))
+
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s: %s", ErrExchangeFailed, resp.Status, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+
+	err = json.Unmarshal(body, &tokens)
+
+	if err != nil {
+		return "", err
+	}
+
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: response contains no id_token", ErrExchangeFailed)
+	}
+
+	return tokens.IDToken, nil
+}
+
+// Verify checks the signature of an ID token against the provider's published keys, along with its
+// issuer, audience, expiry and nonce.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+
+	rawHeader, err := encoding.DecodeString(parts[0])
+
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	err = json.Unmarshal(rawHeader, &header)
+
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	key, err := p.key(ctx, header.KeyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if header.Algorithm != "RS256" || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+			return nil, ErrInvalidIDToken
+		}
+	case *ecdsa.PublicKey:
+		if header.Algorithm != "ES256" || len(signature) != 64 {
+			return nil, ErrInvalidIDToken
+		}
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return nil, ErrInvalidIDToken
+		}
+	default:
+		return nil, ErrInvalidIDToken
+	}
+
+	rawClaims, err := encoding.DecodeString(parts[1])
+
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	var claims Claims
+
+	err = json.Unmarshal(rawClaims, &claims)
+
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := time.Now()
+
+	switch {
+	case claims.Issuer != p.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.ClientID):
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	case now.Add(-leeway).Unix() >= claims.ExpiresAt:
+		return nil, fmt.Errorf("%w: token has expired", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+// key returns the public key with the given ID, refetching the key set when the ID is unknown so
+// that keys the provider rotated in are picked up.
+func (p *Provider) key(ctx context.Context, id string) (crypto.PublicKey, error) {
+	err := p.discover(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < minRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, id)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key, ok := p.keys[id]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, id)
+	}
+
+	return key, nil
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+			Curve   string `json:"crv"`
+			X       string `json:"x"`
+			Y       string `json:"y"`
+		} `json:"keys"`
+	}
+
+	err := p.getJSON(ctx, p.jwksURI, &set)
+
+	if err != nil {
+		return nil, fmt.Errorf("oidc: fetching keys for %s: %w", p.Name, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch k.KeyType {
+		case "RSA":
+			n, errN := encoding.DecodeString(k.N)
+			e, errE := encoding.DecodeString(k.E)
+
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+
+			keys[k.KeyID] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if k.Curve != "P-256" {
+				continue
+			}
+
+			x, errX := encoding.DecodeString(k.X)
+			y, errY := encoding.DecodeString(k.Y)
+
+			if errX != nil || errY != nil {
+				continue
+			}
+
+			key := &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+
+			if !key.Curve.IsOnCurve(key.X, key.Y) {
+				continue
+			}
+
+			keys[k.KeyID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1_048_576)).Decode(dst)
+}
@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "cinego"
+	testKeyID    = "test-key"
+	testCode     = "auth-code"
+)
+
+// mockIdP is an identity provider that issues ID tokens with whatever claims the test sets.
+type mockIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	claims   map[string]any
+	verifier string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{key: key}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   encoding.EncodeToString(key.N.Bytes()),
+				"e":   encoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, _, _ := r.BasicAuth()
+
+		if r.FormValue("code") != testCode || clientID != testClientID || r.FormValue("code_verifier") != idp.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, testKeyID, idp.claims)})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) provider() *Provider {
+	return New(Config{
+		Name:        "mock",
+		Issuer:      idp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/v1/oauth/mock/callback",
+	})
+}
+
+func (idp *mockIdP) sign(t *testing.T, keyID string, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := encoding.EncodeToString(header) + "." + encoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + encoding.EncodeToString(signature)
+}
+
+func (idp *mockIdP) validClaims(nonce string) map[string]any {
+	return map[string]any{
+		"iss":            idp.server.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	verifier, err := GenerateVerifier()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	if got := u.Query().Get("code_challenge"); got != encoding.EncodeToString(challenge[:]) {
+		t.Errorf("code_challenge = %q, want the S256 challenge of the verifier", got)
+	}
+
+	if got := u.Query().Get("nonce"); got != "nonce" {
+		t.Errorf("nonce = %q, want %q", got, "nonce")
+	}
+
+	idp.verifier = verifier
+	idp.claims = idp.validClaims("nonce")
+
+	rawIDToken, err := p.Exchange(ctx, testCode, verifier)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := p.Verify(ctx, rawIDToken, "nonce")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "user-1" || claims.Email != "alice@example.com" || !claims.HasVerifiedEmail() {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejected(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.verifier = "right"
+	p := idp.provider()
+
+	tests := []struct {
+		name     string
+		code     string
+		verifier string
+	}{
+		{"wrong code", "other-code", "right"},
+		{"wrong verifier", testCode, "wrong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Exchange(context.Background(), tt.code, tt.verifier)
+
+			if !errors.Is(err, ErrExchangeFailed) {
+				t.Errorf("got error %v, want ErrExchangeFailed", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+
+	tests := []struct {
+		name   string
+		modify func(claims map[string]any)
+		keyID  string
+		nonce  string
+		tamper bool
+	}{
+		{name: "wrong nonce", nonce: "other"},
+		{name: "wrong issuer", modify: func(c map[string]any) { c["iss"] = "https://evil.example.com" }},
+		{name: "wrong audience", modify: func(c map[string]any) { c["aud"] = []string{"someone-else"} }},
+		{name: "expired", modify: func(c map[string]any) { c["exp"] = time.Now().Add(-2 * leeway).Unix() }},
+		{name: "missing subject", modify: func(c map[string]any) { delete(c, "sub") }},
+		{name: "unknown key", keyID: "other-key"},
+		{name: "tampered payload", tamper: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.validClaims("nonce")
+
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+
+			keyID := testKeyID
+
+			if tt.keyID != "" {
+				keyID = tt.keyID
+			}
+
+			nonce := "nonce"
+
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			token := idp.sign(t, keyID, claims)
+
+			if tt.tamper {
+				parts := strings.Split(token, ".")
+				claims["sub"] = "admin"
+				payload, _ := json.Marshal(claims)
+				token = parts[0] + "." + encoding.EncodeToString(payload) + "." + parts[2]
+			}
+
+			_, err := p.Verify(context.Background(), token, nonce)
+
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("got error %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestHasVerifiedEmail(t *testing.T) {
+	verified := true
+	unverified := false
+
+	tests := []struct {
+		name   string
+		claims Claims
+		want   bool
+	}{
+		{"verified", Claims{Email: "a@example.com", EmailVerified: &verified}, true},
+		{"unverified", Claims{Email: "a@example.com", EmailVerified: &unverified}, false},
+		{"claim missing", Claims{Email: "a@example.com"}, false},
+		{"no email", Claims{EmailVerified: &verified}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.HasVerifiedEmail(); got != tt.want {
+				t.Errorf("HasVerifiedEmail() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
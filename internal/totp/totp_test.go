@@ -0,0 +1,111 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// secret is the shared secret of the test vectors in RFC 4226 and RFC 6238.
+var secret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226, appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := hotp(secret, uint64(counter)); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	// RFC 6238, appendix B, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := Code(secret, time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / Period
+
+	tests := []struct {
+		name     string
+		code     string
+		valid    bool
+		wantStep int64
+	}{
+		{"current step", Code(secret, now), true, step},
+		{"previous step", Code(secret, now.Add(-Period*time.Second)), true, step - 1},
+		{"next step", Code(secret, now.Add(Period*time.Second)), true, step + 1},
+		{"two steps old", Code(secret, now.Add(-2*Period*time.Second)), false, 0},
+		{"two steps ahead", Code(secret, now.Add(2*Period*time.Second)), false, 0},
+		{"too short", Code(secret, now)[:5], false, 0},
+		{"too long", Code(secret, now) + "0", false, 0},
+		{"empty", "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, valid := Validate(secret, tt.code, now)
+
+			if valid != tt.valid || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, valid, tt.wantStep, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateOtherSecret(t *testing.T) {
+	other, err := GenerateSecret()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	if _, valid := Validate(other, Code(secret, now), now); valid {
+		t.Error("code for another secret was accepted")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Cinego", "alice@example.com", secret)
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Cinego:alice@example.com" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+
+	qs := u.Query()
+
+	if qs.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("secret = %s, want the base32 encoded secret without padding", qs.Get("secret"))
+	}
+
+	if qs.Get("digits") != "6" || qs.Get("period") != "30" || qs.Get("issuer") != "Cinego" {
+		t.Errorf("unexpected parameters %s", u.RawQuery)
+	}
+}
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE permissions ADD CONSTRAINT permissions_code_key UNIQUE (code);
+
+INSERT INTO permissions(code)
+VALUES ('users:admin')
+ON CONFLICT (code) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE code = 'users:admin';
+
+ALTER TABLE permissions DROP CONSTRAINT IF EXISTS permissions_code_key;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS reviews
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id   bigint                      NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    user_id    bigint                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    rating     smallint                    NOT NULL CHECK (rating BETWEEN 1 AND 10),
+    body       text                        NOT NULL DEFAULT '',
+    version    integer                     NOT NULL DEFAULT 1,
+    UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+INSERT INTO permissions(code)
+VALUES ('reviews:moderate')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id
+FROM roles
+         INNER JOIN permissions ON roles.name = 'admin' AND permissions.code = 'reviews:moderate'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reviews;
+
+DELETE FROM permissions WHERE code = 'reviews:moderate';
+-- +goose StatementEnd
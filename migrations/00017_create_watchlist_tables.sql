@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS watchlist
+(
+    user_id  bigint                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id bigint                      NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    position integer                     NOT NULL,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE TABLE IF NOT EXISTS watch_history
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id    bigint                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id   bigint                      NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    watched_at date                        NOT NULL,
+    rating     smallint CHECK (rating BETWEEN 1 AND 10)
+);
+
+CREATE INDEX IF NOT EXISTS watch_history_user_id_idx ON watch_history (user_id, watched_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS watch_history;
+DROP TABLE IF EXISTS watchlist;
+-- +goose StatementEnd
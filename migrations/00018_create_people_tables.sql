@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS people
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name       text                        NOT NULL,
+    birth_date date,
+    biography  text                        NOT NULL DEFAULT '',
+    version    integer                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people USING GIN (to_tsvector('simple', name));
+
+CREATE TABLE IF NOT EXISTS movie_credits
+(
+    id        bigserial PRIMARY KEY,
+    movie_id  bigint  NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    person_id bigint  NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    role      text    NOT NULL CHECK (role IN ('director', 'actor', 'writer')),
+    character text    NOT NULL DEFAULT '',
+    position  integer NOT NULL DEFAULT 0,
+    UNIQUE (movie_id, person_id, role, character)
+);
+
+CREATE INDEX IF NOT EXISTS movie_credits_person_id_idx ON movie_credits (person_id, role);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movie_credits;
+DROP TABLE IF EXISTS people;
+-- +goose StatementEnd
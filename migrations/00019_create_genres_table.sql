@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS genres
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name       text                        NOT NULL,
+    version    integer                     NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS genres_name_idx ON genres (lower(name));
+
+-- lowercase the existing genres, fold common spellings of the same genre together and drop the
+-- duplicates this creates, keeping the original order
+UPDATE movies
+SET genres = ARRAY(
+        SELECT normalised.name
+        FROM unnest(movies.genres) WITH ORDINALITY AS genre(name, position),
+             LATERAL (
+                 SELECT CASE regexp_replace(lower(trim(genre.name)), '[\s_]+', ' ', 'g')
+                            WHEN 'sci-fi' THEN 'science fiction'
+                            WHEN 'scifi' THEN 'science fiction'
+                            WHEN 'sf' THEN 'science fiction'
+                            WHEN 'science-fiction' THEN 'science fiction'
+                            WHEN 'rom-com' THEN 'romantic comedy'
+                            WHEN 'romcom' THEN 'romantic comedy'
+                            WHEN 'animated' THEN 'animation'
+                            ELSE regexp_replace(lower(trim(genre.name)), '[\s_]+', ' ', 'g')
+                            END AS name
+                 ) AS normalised
+        GROUP BY normalised.name
+        ORDER BY MIN(genre.position)
+    );
+
+INSERT INTO genres(name)
+SELECT DISTINCT unnest(genres)
+FROM movies
+ORDER BY 1;
+
+INSERT INTO permissions(code)
+VALUES ('genres:write')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id
+FROM roles
+         INNER JOIN permissions ON roles.name = 'admin' AND permissions.code = 'genres:write'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS genres;
+
+DELETE FROM permissions WHERE code = 'genres:write';
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE movies
+    ADD COLUMN IF NOT EXISTS original_title       text    NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS synopsis             text    NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS release_date         date,
+    ADD COLUMN IF NOT EXISTS spoken_languages     text[]  NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS production_countries text[]  NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS certifications       jsonb   NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS imdb_id              text,
+    ADD COLUMN IF NOT EXISTS tmdb_id              bigint;
+
+ALTER TABLE movies ADD CONSTRAINT movies_imdb_id_key UNIQUE (imdb_id);
+ALTER TABLE movies ADD CONSTRAINT movies_tmdb_id_key UNIQUE (tmdb_id);
+ALTER TABLE movies ADD CONSTRAINT movies_imdb_id_check CHECK (imdb_id ~ '^tt[0-9]{7,10}$');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS original_title,
+    DROP COLUMN IF EXISTS synopsis,
+    DROP COLUMN IF EXISTS release_date,
+    DROP COLUMN IF EXISTS spoken_languages,
+    DROP COLUMN IF EXISTS production_countries,
+    DROP COLUMN IF EXISTS certifications,
+    DROP COLUMN IF EXISTS imdb_id,
+    DROP COLUMN IF EXISTS tmdb_id;
+-- +goose StatementEnd
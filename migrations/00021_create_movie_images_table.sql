@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS movie_images
+(
+    movie_id     bigint                      NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    kind         text                        NOT NULL CHECK (kind IN ('poster', 'backdrop')),
+    key_prefix   text                        NOT NULL,
+    content_type text                        NOT NULL,
+    width        integer                     NOT NULL,
+    height       integer                     NOT NULL,
+    updated_at   timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, kind)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movie_images;
+-- +goose StatementEnd
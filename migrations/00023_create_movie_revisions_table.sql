@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS movie_revisions
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id   bigint                      NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    version    integer                     NOT NULL,
+    action     text                        NOT NULL CHECK (action IN ('insert', 'update', 'delete', 'restore', 'revert')),
+    user_id    bigint                      REFERENCES users (id) ON DELETE SET NULL,
+    changes    jsonb                       NOT NULL,
+    snapshot   jsonb                       NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS movie_revisions_movie_id_idx ON movie_revisions (movie_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS movie_revisions;
+-- +goose StatementEnd
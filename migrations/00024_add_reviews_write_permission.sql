@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO permissions(code)
+VALUES ('reviews:write')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id
+FROM roles
+         INNER JOIN permissions ON roles.name IN ('viewer', 'editor', 'admin') AND permissions.code = 'reviews:write'
+ON CONFLICT DO NOTHING;
+
+-- everyone who could write reviews through movies:read keeps doing so
+INSERT INTO users_permissions
+SELECT users_permissions.user_id, reviews_write.id
+FROM users_permissions
+         INNER JOIN permissions movies_read ON movies_read.id = users_permissions.permission_id AND movies_read.code = 'movies:read'
+         CROSS JOIN permissions reviews_write
+WHERE reviews_write.code = 'reviews:write'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE code = 'reviews:write';
+-- +goose StatementEnd
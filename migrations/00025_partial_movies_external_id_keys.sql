@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- movies in the trash don't hold on to their external IDs, so they can be added again. the indexes
+-- keep the names of the constraints they replace, which is what duplicate key errors are matched on
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_imdb_id_key;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_tmdb_id_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS movies_imdb_id_key ON movies (imdb_id) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_key ON movies (tmdb_id) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS movies_imdb_id_key;
+DROP INDEX IF EXISTS movies_tmdb_id_key;
+
+ALTER TABLE movies ADD CONSTRAINT movies_imdb_id_key UNIQUE (imdb_id);
+ALTER TABLE movies ADD CONSTRAINT movies_tmdb_id_key UNIQUE (tmdb_id);
+-- +goose StatementEnd